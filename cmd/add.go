@@ -17,8 +17,9 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -26,8 +27,8 @@ import (
 )
 
 const addLong = `Enables addition of a new kubectl config file to kubeconfig registry, by
-creating a new empty file with the provided name and opening editor described by
-the ${EDITOR} environment variable.`
+creating a new empty file with the provided name and opening editor set in
+settings or described by the ${EDITOR} environment variable.`
 
 // newAddCmd generates a new add command.
 func newAddCmd(global *rootOpts) *cobra.Command {
@@ -45,33 +46,46 @@ func newAddCmd(global *rootOpts) *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&o.editor, "editor", "e", "", "sets the editor used directly, instead of using the ${EDITOR} environment variable")
+	cmd.Flags().StringVarP(&o.editor, "editor", "e", "", "sets the editor used directly, instead of using the one from settings or the ${EDITOR} environment variable")
 	cmd.Flags().BoolVarP(&o.force, "force", "f", false, "force override, if the entry with the provided name already exists in the registry")
+	o.expiryOpts.addFlags(cmd.Flags())
 
 	return cmd
 }
 
 type addOpts struct {
+	expiryOpts
 	name   string
 	editor string
 	force  bool
 }
 
 func addRun(ctx context.Context, g *rootOpts, o *addOpts) error {
-	editor := o.editor
-	if editor == "" {
-		e, ok := os.LookupEnv("EDITOR")
-		if !ok {
-			return fmt.Errorf("$EDITOR environment vatiabble not set")
-		}
-		editor = e
+	editor, err := g.editor(o.editor)
+	if err != nil {
+		return err
+	}
+
+	expires, err := o.expiry(time.Now())
+	if err != nil {
+		return err
 	}
 
-	reg, err := g.regPath()
+	regs, err := g.registries()
 	if err != nil {
 		return err
 	}
 
+	entry, err := regs.Target(o.name)
+	if err != nil {
+		return err
+	}
+	if err := regs.CheckWritable(entry); err != nil {
+		return lockHint(err)
+	}
+
+	hook := hookEnv{to: entry.QualifiedName(), toPath: entry.Path}
+
 	tmp, err := os.CreateTemp("", "*.config")
 	if err != nil {
 		return fmt.Errorf("cannot create temporary file: %w", err)
@@ -82,15 +96,8 @@ func addRun(ctx context.Context, g *rootOpts, o *addOpts) error {
 	}
 	defer os.Remove(tmpPath)
 
-	cmd := exec.CommandContext(ctx, editor, tmpPath)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("starting editor %q failed: %w", editor, err)
-	}
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("editor %q failed: %w", editor, err)
+	if err := runEditor(ctx, editor, tmpPath); err != nil {
+		return err
 	}
 
 	if tmp, err = os.Open(tmpPath); err != nil {
@@ -107,17 +114,62 @@ func addRun(ctx context.Context, g *rootOpts, o *addOpts) error {
 		return nil // just delete temporary
 	}
 
-	path := registry.NameToPath(reg, o.name)
-	if o.force {
-		if err := registry.ForceWrite(path, tmp); err != nil {
-			return err
+	if err := writeEntry(g, regs, entry, tmp, o.force, g.preHook(ctx, hookPreAdd, hook)); err != nil {
+		return err
+	}
+	if err := setExpiry(regs, entry, expires); err != nil {
+		return err
+	}
+
+	fmt.Printf("A new entry %q added to the registry.\n", regs.DisplayName(entry))
+	g.runPostHook(ctx, hookPostAdd, hook)
+	return nil
+}
+
+// writeEntry writes the given content under the provided name in the registry. Overwriting an existing entry is
+// subject to the overwrite confirmation policy and the old content is moved to the trash. The expiry time of the old
+// content is cleared (commands set a new one with setExpiry, when requested). The pre function (if not nil, usually
+// running a pre-hook) is called right before writing, after the overwrite is confirmed, and can abort it.
+func writeEntry(g *rootOpts, regs *registry.Registries, entry registry.Entry, content io.Reader, force bool, pre func() error) error {
+	if err := regs.CheckWritable(entry); err != nil {
+		return lockHint(err)
+	}
+	exist, err := registry.Exist(entry.Source.Path, entry.Name)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		if pre != nil {
+			if err := pre(); err != nil {
+				return err
+			}
 		}
-	} else {
-		if err := registry.Write(path, tmp); err != nil {
+		return regs.Write(entry, content, false)
+	}
+
+	name := regs.DisplayName(entry)
+	err = g.confirm(
+		"confirm.overwrite", force,
+		fmt.Sprintf("Entry %q already exists in the registry, overwrite it", name),
+		fmt.Sprintf("entry %q already exists in the registry; If overwriting it is intended force with '--force' flag", name),
+	)
+	if err != nil {
+		return err
+	}
+	if pre != nil {
+		if err := pre(); err != nil {
 			return err
 		}
 	}
+	if err := regs.Write(entry, content, true); err != nil {
+		return lockHint(err)
+	}
 
-	fmt.Printf("A new entry %q added to the registry.\n", o.name)
-	return nil
+	meta, err := regs.Metadata(entry)
+	if err != nil || meta.Expires == nil {
+		return err
+	}
+	updated := *meta
+	updated.Expires = nil
+	return regs.SetMetadata(entry, &updated)
 }
@@ -0,0 +1,299 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/registry"
+)
+
+const exportLong = `Exports entries (all of them, the given ones or ones matching the label
+selector) together with their metadata as a gzip compressed tar archive with a
+manifest, that can be imported with 'kubeconfig import-bundle'.
+
+The archive can be encrypted with a passphrase ('--encrypt' prompts for it,
+'--passphrase-file' reads it from the first line of the given file).`
+
+// newExportCmd generates a new export command.
+func newExportCmd(global *rootOpts) *cobra.Command {
+	o := &exportOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "export [names...]",
+		Short: "Export entries as an archive",
+		Long:  exportLong,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.names = args
+			return exportRun(global, o)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "path of the created archive ('-' for the standard output)")
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "export only entries matching the label selector (e.g. 'team=payments')")
+	cmd.Flags().BoolVar(&o.encrypt, "encrypt", false, "encrypt the archive with a passphrase entered interactively")
+	cmd.Flags().StringVar(&o.passphraseFile, "passphrase-file", "", "encrypt the archive with a passphrase read from the given file")
+	cmd.Flags().BoolVarP(&o.force, "force", "f", false, "force override, if the output file already exists")
+	_ = cmd.MarkFlagRequired("output")
+
+	return cmd
+}
+
+type exportOpts struct {
+	names          []string
+	output         string
+	selector       string
+	encrypt        bool
+	passphraseFile string
+	force          bool
+}
+
+func exportRun(g *rootOpts, o *exportOpts) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+
+	entries, err := lookupOrList(regs, o.names, o.selector)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no matching entries in the registry")
+	}
+
+	passphrase, err := bundlePassphrase(o.passphraseFile, o.encrypt, true)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := regs.WriteBundle(buf, entries, passphrase); err != nil {
+		return err
+	}
+	if o.output == "-" {
+		_, err := io.Copy(os.Stdout, buf)
+		return err
+	}
+	if o.force {
+		err = registry.ForceWrite(o.output, buf)
+	} else {
+		err = registry.Write(o.output, buf)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d entries to %q.\n", len(entries), o.output)
+	return nil
+}
+
+// bundlePassphrase returns the passphrase of a bundle read from the given file or, if requested, entered interactively
+// (twice, when confirm is set). An empty passphrase means no encryption.
+func bundlePassphrase(file string, prompt bool, confirm bool) (string, error) {
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("cannot read passphrase file %q: %w", file, err)
+		}
+		passphrase := strings.TrimRight(strings.SplitN(string(b), "\n", 2)[0], "\r")
+		if passphrase == "" {
+			return "", fmt.Errorf("passphrase file %q is empty", file)
+		}
+		return passphrase, nil
+	}
+	if !prompt {
+		return "", nil
+	}
+	passphrase, err := ui.Password("Passphrase")
+	if err != nil {
+		return "", fmt.Errorf("cannot read passphrase: %w; Use '--passphrase-file' flag instead", err)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("empty passphrase")
+	}
+	if confirm {
+		repeated, err := ui.Password("Repeat passphrase")
+		if err != nil {
+			return "", err
+		}
+		if repeated != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+const importBundleLong = `Imports entries (together with their metadata) from an archive created with
+'kubeconfig export'. Hashes of all entries are verified against the manifest
+before anything is written.
+
+Entries already present in the registry with different content are handled
+according to the conflict strategy: 'skip' (default) leaves them intact,
+'overwrite' replaces them (the old content goes to the trash) and 'rename'
+imports them under a new name with a numeric suffix.
+
+Entries are imported into their original registry, if it is configured and
+writable, or into the write registry otherwise.`
+
+// Conflict strategies of the import-bundle command.
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictRename    = "rename"
+)
+
+// newImportBundleCmd generates a new import-bundle command.
+func newImportBundleCmd(global *rootOpts) *cobra.Command {
+	o := &importBundleOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "import-bundle [archive]",
+		Short: "Import entries from an archive",
+		Long:  importBundleLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.file = args[0]
+			return importBundleRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.conflict, "conflict", conflictSkip, "strategy for entries already present in the registry (skip, overwrite or rename)")
+	cmd.Flags().StringVar(&o.passphraseFile, "passphrase-file", "", "decrypt the archive with a passphrase read from the given file")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "only show what would be imported")
+
+	return cmd
+}
+
+type importBundleOpts struct {
+	file           string
+	conflict       string
+	passphraseFile string
+	dryRun         bool
+}
+
+func importBundleRun(ctx context.Context, g *rootOpts, o *importBundleOpts) error {
+	switch o.conflict {
+	case conflictSkip, conflictOverwrite, conflictRename:
+	default:
+		return fmt.Errorf("unknown conflict strategy %q (expected skip, overwrite or rename)", o.conflict)
+	}
+
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(o.file)
+	if err != nil {
+		return fmt.Errorf("cannot read file %q: %w", o.file, err)
+	}
+	passphrase, err := bundlePassphrase(o.passphraseFile, false, false)
+	if err != nil {
+		return err
+	}
+	_, entries, err := registry.ReadBundle(bytes.NewReader(content), passphrase)
+	if errors.Is(err, registry.ErrPassphraseRequired) && o.passphraseFile == "" {
+		if passphrase, err = bundlePassphrase("", true, false); err != nil {
+			return err
+		}
+		_, entries, err = registry.ReadBundle(bytes.NewReader(content), passphrase)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, be := range entries {
+		name := be.Name
+		if s, ok := regs.Source(be.Registry); ok && !s.ReadOnly {
+			name = s.Name + registry.NameSeparator + name // keep entries in their original registry, when possible
+		}
+		entry, err := regs.Target(name)
+		if err != nil {
+			return err
+		}
+		action, err := importBundleAction(regs, &entry, be, o.conflict)
+		if err != nil {
+			return err
+		}
+		name = regs.DisplayName(entry)
+		if action == "" {
+			fmt.Printf("Skipping %q (already present).\n", be.Name)
+			continue
+		}
+		if o.dryRun {
+			fmt.Printf("Would import %q as %q (%s).\n", be.Name, name, action)
+			continue
+		}
+		hook := hookEnv{fromPath: o.file, to: entry.QualifiedName(), toPath: entry.Path}
+		if err := writeEntry(g, regs, entry, bytes.NewReader(be.Content), true, g.preHook(ctx, hookPreImport, hook)); err != nil {
+			return err
+		}
+		if be.Metadata != nil {
+			if err := regs.SetMetadata(entry, be.Metadata); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Imported %q as %q (%s).\n", be.Name, name, action)
+		g.runPostHook(ctx, hookPostImport, hook)
+	}
+	return nil
+}
+
+// importBundleAction resolves the conflict of the imported entry with the existing one, according to the given strategy.
+// It returns the description of the action (an empty string, when the entry should be skipped) and, when renaming,
+// updates the target entry.
+func importBundleAction(regs *registry.Registries, entry *registry.Entry, be registry.BundleEntry, strategy string) (string, error) {
+	exist, err := registry.Exist(entry.Source.Path, entry.Name)
+	if err != nil || !exist {
+		return "new", err
+	}
+	h, err := registry.Hash(entry.Path)
+	if err != nil {
+		return "", err
+	}
+	if fmt.Sprintf("%x", h) == be.Hash {
+		return "", nil
+	}
+
+	switch strategy {
+	case conflictOverwrite:
+		return "overwritten", nil
+	case conflictRename:
+		for i := 1; ; i++ {
+			renamed, err := regs.Target(fmt.Sprintf("%s%s%s-%d", entry.Source.Name, registry.NameSeparator, be.Name, i))
+			if err != nil {
+				return "", err
+			}
+			exist, err := registry.Exist(renamed.Source.Path, renamed.Name)
+			if err != nil {
+				return "", err
+			}
+			if !exist {
+				*entry = renamed
+				return "renamed", nil
+			}
+		}
+	}
+	return "", nil
+}
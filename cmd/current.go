@@ -23,8 +23,15 @@ import (
 
 	"github.com/daishe/kubeconfig/cmd/ui"
 	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
 )
 
+const currentLong = `Displays under what name the current kubectl config file is known to kubeconfig.
+
+When the shell session uses an entry set by 'kubeconfig env --export-entry' or
+the directory hook (the ${KUBECONFIG_ENTRY} environment variable), that entry
+is reported instead, unless '--global' is used.`
+
 // newCurrentCmd generates a new current command
 func newCurrentCmd(global *rootOpts) *cobra.Command {
 	o := &currentOpts{}
@@ -32,7 +39,7 @@ func newCurrentCmd(global *rootOpts) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "current",
 		Short:   "Show the current kubectl config file",
-		Long:    `Displays under what name the current kubectl config file is known to kubeconfig.`,
+		Long:    currentLong,
 		Aliases: []string{"curr", "cur", "c"},
 		Run: func(cmd *cobra.Command, args []string) {
 			currentRun(global, o)
@@ -40,18 +47,35 @@ func newCurrentCmd(global *rootOpts) *cobra.Command {
 	}
 
 	cmd.Flags().BoolVarP(&o.dumpConfig, "dump", "d", false, "dump the content of the kubectl config file instead of reporting kubeconfig name")
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format (text, json or yaml), instead of the one set in settings")
+	cmd.Flags().BoolVar(&o.global, "global", false, "ignore the entry used by the shell session")
 
 	return cmd
 }
 
 type currentOpts struct {
 	dumpConfig bool
+	output     string
+	global     bool
+}
+
+type currentItem struct {
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Registry string `json:"registry,omitempty" yaml:"registry,omitempty"`
+	Found    bool   `json:"found" yaml:"found"`
+	Path     string `json:"path" yaml:"path"`
+	Session  bool   `json:"session" yaml:"session"`
 }
 
 func currentRun(g *rootOpts, o *currentOpts) {
+	session := !o.global && os.Getenv(envEntry) != ""
+
 	if o.dumpConfig {
-		kcCfgPath, err := registry.KubectlConfigPath()
+		kcCfgPath, err := g.kubectlConfigPath()
 		ui.DisplayAndExitOnError(err)
+		if session && os.Getenv(envKubeconfig) != "" {
+			kcCfgPath = os.Getenv(envKubeconfig)
+		}
 
 		toShow, err := registry.Read(kcCfgPath)
 		ui.DisplayAndExitOnError(err)
@@ -63,20 +87,45 @@ func currentRun(g *rootOpts, o *currentOpts) {
 		return
 	}
 
-	kcCfgPath, err := registry.KubectlConfigPath()
+	out, err := g.output(o.output)
+	ui.DisplayAndExitOnError(err)
+
+	kcCfgPath, err := g.kubectlConfigPath()
 	ui.DisplayAndExitOnError(err)
 
-	reg, err := g.regPath()
+	regs, err := g.registries()
 	ui.DisplayAndExitOnError(err)
 
+	if session {
+		current, _, err := sessionEntry(regs)
+		ui.DisplayAndExitOnError(err)
+		if out != settings.OutputText {
+			item := currentItem{Name: regs.DisplayName(current), Registry: current.Source.Name, Found: true, Path: os.Getenv(envKubeconfig), Session: true}
+			ui.DisplayAndExitOnError(ui.PrintStructured(out, item))
+			return
+		}
+		fmt.Println(regs.DisplayName(current))
+		return
+	}
+
 	kcCfgHash, err := registry.Hash(kcCfgPath)
 	ui.DisplayAndExitOnError(err)
 
-	currentPath, found, err := registry.Find(reg, kcCfgHash)
+	current, found, err := regs.Find(kcCfgHash)
 	ui.DisplayAndExitOnError(err)
 
+	if out != settings.OutputText {
+		item := currentItem{Found: found, Path: kcCfgPath}
+		if found {
+			item.Name = regs.DisplayName(current)
+			item.Registry = current.Source.Name
+		}
+		ui.DisplayAndExitOnError(ui.PrintStructured(out, item))
+		return
+	}
+
 	if found {
-		fmt.Println(registry.PathToName(reg, currentPath))
+		fmt.Println(regs.DisplayName(current))
 	} else {
 		fmt.Println("Current kubectl config file is not in the registry.")
 	}
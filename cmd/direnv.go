@@ -0,0 +1,525 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/sha3"
+	"gopkg.in/yaml.v3"
+
+	"github.com/daishe/kubeconfig/kubecfg"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
+	"github.com/daishe/kubeconfig/state"
+)
+
+// entryFileName is the name of the file selecting the registry entry used in a project directory (and below it).
+const entryFileName = ".kubeconfig-entry"
+
+// Environment variables describing the entry used by the shell session and the state of the directory hook.
+const (
+	envKubeconfig       = "KUBECONFIG"
+	envEntry            = "KUBECONFIG_ENTRY"
+	envDirFile          = "KUBECONFIG_DIR_FILE"
+	envDirHash          = "KUBECONFIG_DIR_HASH"
+	envDirPrevious      = "KUBECONFIG_DIR_PREVIOUS"
+	envDirPreviousEntry = "KUBECONFIG_DIR_PREVIOUS_ENTRY"
+	envDirBlocked       = "KUBECONFIG_DIR_BLOCKED"
+	previousUnset       = "unset"
+	previousSetPrefix   = "set:"
+)
+
+// entryFile describes the content of the '.kubeconfig-entry' file. The file holds either just the entry name or a
+// mapping with the entry name and optionally the context and the namespace.
+type entryFile struct {
+	Entry     string `yaml:"entry"`
+	Context   string `yaml:"context,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// readEntryFile reads the '.kubeconfig-entry' file and returns its content together with its hash.
+func readEntryFile(path string) (*entryFile, string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read file %q: %w", path, err)
+	}
+	hash := sha3.Sum256(b)
+
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(b, node); err != nil {
+		return nil, "", fmt.Errorf("cannot parse file %q: %w", path, err)
+	}
+	ef := &entryFile{}
+	if len(node.Content) > 0 && node.Content[0].Kind == yaml.ScalarNode {
+		ef.Entry = node.Content[0].Value
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(ef); err != nil {
+			return nil, "", fmt.Errorf("cannot parse file %q: %w", path, err)
+		}
+	}
+	if ef.Entry = strings.TrimSpace(ef.Entry); ef.Entry == "" {
+		return nil, "", fmt.Errorf("file %q names no entry", path)
+	}
+	return ef, fmt.Sprintf("%x", hash), nil
+}
+
+// findEntryFile returns the path of the '.kubeconfig-entry' file in the given directory or the closest parent one.
+func findEntryFile(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		path := filepath.Join(dir, entryFileName)
+		if stat, err := os.Stat(path); err == nil && stat.Mode().IsRegular() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// resolveEntryFile returns the absolute path of the '.kubeconfig-entry' file given by path (of the file or the
+// directory holding it) or, if empty, the one closest to the current directory.
+func resolveEntryFile(path string) (string, error) {
+	if path == "" {
+		found, ok := findEntryFile(".")
+		if !ok {
+			return "", fmt.Errorf("no %s file in the current directory nor its parents", entryFileName)
+		}
+		return found, nil
+	}
+	if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+		path = filepath.Join(path, entryFileName)
+	}
+	return filepath.Abs(path)
+}
+
+// materialize writes a private copy of the entry (with the given context set as the current one and the given
+// namespace set for it, if not empty) to the state directory and returns its path.
+func materialize(entry registry.Entry, context string, namespace string) (string, error) {
+	b, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read file %q: %w", entry.Path, err)
+	}
+	if context != "" || namespace != "" {
+		cfg, err := kubecfg.Parse(b)
+		if err != nil {
+			return "", err
+		}
+		if context != "" {
+			if _, ok := cfg.Context(context); !ok {
+				return "", fmt.Errorf("context %q does not exist in entry %q", context, entry.QualifiedName())
+			}
+			cfg.CurrentContext = context
+		}
+		if namespace != "" {
+			c, ok := cfg.Context(cfg.CurrentContext)
+			if !ok {
+				return "", fmt.Errorf("entry %q has no current context to set the namespace for", entry.QualifiedName())
+			}
+			c.Context.Namespace = namespace
+		}
+		if b, err = cfg.Encode(); err != nil {
+			return "", err
+		}
+	}
+
+	stateDir, err := settings.StateDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(stateDir, "sessions")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("cannot create directory %q: %w", dir, err)
+	}
+	id := sha3.Sum256([]byte(entry.QualifiedName() + "\x00" + context + "\x00" + namespace))
+	path := filepath.Join(dir, fmt.Sprintf("%x-%s.yaml", id[:8], filepath.Base(entry.Name)))
+	if err := registry.ForceWrite(path, bytes.NewReader(b)); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return "", fmt.Errorf("cannot set mode of file %q: %w", path, err)
+	}
+	return path, nil
+}
+
+const initLong = `Prints the shell hook, that selects the registry entry named in the
+'.kubeconfig-entry' file of the current directory (or the closest parent one)
+when entering it, by pointing ${KUBECONFIG} of the shell session to a private
+copy of the entry (the active kubectl config file is not touched), and restores
+the previous ${KUBECONFIG} when leaving.
+
+The file holds the entry name, or a mapping with the entry name and optionally
+the context and the namespace:
+
+  entry: aws/prod
+  context: admin@prod
+  namespace: payments
+
+Only files allowed with 'kubeconfig allow' are used (and changed files have to
+be allowed again), so that untrusted repositories cannot redirect you. Trust is
+checked on every prompt, so changed or denied files stop being used at once.
+Files selecting protected entries have to be allowed with
+'kubeconfig allow --protected'.
+
+To enable the hook, add to your shell configuration:
+
+  bash (~/.bashrc):                 eval "$(kubeconfig init bash)"
+  zsh (~/.zshrc):                   eval "$(kubeconfig init zsh)"
+  fish (~/.config/fish/config.fish): kubeconfig init fish | source
+  PowerShell ($PROFILE):            Invoke-Expression (& kubeconfig init powershell | Out-String)`
+
+// newInitCmd generates a new init command.
+func newInitCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "init [shell]",
+		Short:     "Print the shell hook selecting entries by directory",
+		Long:      initLong,
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: shellNames,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			return initRun(name)
+		},
+	}
+
+	return cmd
+}
+
+func initRun(name string) error {
+	shell, err := resolveShell(name)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot determine path of the kubeconfig executable: %w", err)
+	}
+	exe = shellQuote(shell, exe)
+
+	switch shell {
+	case shellBash:
+		fmt.Printf(`_kubeconfig_hook() {
+  local previous_exit_status=$?
+  eval "$(%s hook-env --shell bash)"
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_kubeconfig_hook;"* ]]; then
+  PROMPT_COMMAND="_kubeconfig_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`, exe)
+	case shellZsh:
+		fmt.Printf(`_kubeconfig_hook() {
+  eval "$(%s hook-env --shell zsh)"
+}
+typeset -ag precmd_functions chpwd_functions
+if (( ! ${precmd_functions[(I)_kubeconfig_hook]} )); then
+  precmd_functions=(_kubeconfig_hook $precmd_functions)
+fi
+if (( ! ${chpwd_functions[(I)_kubeconfig_hook]} )); then
+  chpwd_functions=(_kubeconfig_hook $chpwd_functions)
+fi
+`, exe)
+	case shellFish:
+		fmt.Printf(`function __kubeconfig_hook --on-variable PWD --description 'select kubeconfig entry by directory'
+  %s hook-env --shell fish | source
+end
+__kubeconfig_hook
+`, exe)
+	case shellPowerShell:
+		fmt.Printf(`$global:__KubeconfigOriginalPrompt = $function:prompt
+function global:prompt {
+  Invoke-Expression (& %s hook-env --shell powershell | Out-String)
+  & $global:__KubeconfigOriginalPrompt
+}
+`, exe)
+	}
+	return nil
+}
+
+// newHookEnvCmd generates a new (hidden) hook-env command.
+func newHookEnvCmd(global *rootOpts) *cobra.Command {
+	o := &hookEnvOpts{}
+
+	cmd := &cobra.Command{
+		Use:    "hook-env",
+		Short:  "Print statements updating the shell session for the current directory",
+		Long:   `Prints statements updating the shell session for the current directory. It is run by the shell hook printed by 'kubeconfig init'.`,
+		Args:   cobra.NoArgs,
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hookEnvRun(global, o)
+			return nil // errors are reported as warnings, so that they do not break the prompt
+		},
+	}
+
+	cmd.Flags().StringVar(&o.shell, "shell", "", "shell syntax (bash, zsh, fish or powershell)")
+
+	return cmd
+}
+
+type hookEnvOpts struct {
+	shell string
+}
+
+func hookEnvRun(g *rootOpts, o *hookEnvOpts) {
+	shell, err := resolveShell(o.shell)
+	if err != nil {
+		hookWarn(err)
+		return
+	}
+	path, found := findEntryFile(".")
+	active := os.Getenv(envDirFile)
+	if found && path == active {
+		// still in the active directory; trust is checked on every prompt, so that changed or denied files stop
+		// being used immediately
+		_, hash, err := readEntryFile(path)
+		if err == nil && hash == os.Getenv(envDirHash) {
+			st, err := state.Load()
+			if err != nil {
+				hookWarn(err)
+				return
+			}
+			if st.IsAllowed(path, hash) {
+				return
+			}
+		}
+	}
+
+	out := []string{}
+	previous, previousEntry := previousUnset, ""
+	if v, ok := os.LookupEnv(envKubeconfig); ok {
+		previous = previousSetPrefix + v
+	}
+	previousEntry = os.Getenv(envEntry)
+	if active != "" {
+		// leave the active directory, restoring the session
+		previous, previousEntry = os.Getenv(envDirPrevious), os.Getenv(envDirPreviousEntry)
+		out = append(out, restoreStatements(shell, previous, previousEntry)...)
+		if path != active {
+			fmt.Fprintf(os.Stderr, "kubeconfig: left %s\n", filepath.Dir(active))
+		}
+	}
+	defer func() {
+		fmt.Println(strings.Join(out, "\n"))
+	}()
+
+	if !found {
+		if os.Getenv(envDirBlocked) != "" {
+			out = append(out, shellUnset(shell, envDirBlocked))
+		}
+		return
+	}
+
+	ef, hash, err := readEntryFile(path)
+	if err != nil {
+		hookWarn(err)
+		return
+	}
+	st, err := state.Load()
+	if err != nil {
+		hookWarn(err)
+		return
+	}
+	if !st.IsAllowed(path, hash) {
+		if os.Getenv(envDirBlocked) != path {
+			hookWarn(fmt.Errorf("%s is not allowed; Review it and run 'kubeconfig allow' to trust it", path))
+			out = append(out, shellExport(shell, envDirBlocked, path))
+		}
+		return
+	}
+
+	regs, err := g.registries()
+	if err != nil {
+		hookWarn(err)
+		return
+	}
+	entry, err := regs.Lookup(ef.Entry)
+	if err != nil {
+		hookWarn(fmt.Errorf("%s: %w", path, err))
+		return
+	}
+	protected, err := isProtected(g, regs, entry)
+	if err != nil {
+		hookWarn(err)
+		return
+	}
+	if protected && !st.IsAllowedProtected(path) {
+		if os.Getenv(envDirBlocked) != path {
+			hookWarn(fmt.Errorf("%s selects protected entry %q; Run 'kubeconfig allow --protected' to use it anyway", path, regs.DisplayName(entry)))
+			out = append(out, shellExport(shell, envDirBlocked, path))
+		}
+		return
+	}
+	kubeconfig, err := materialize(entry, ef.Context, ef.Namespace)
+	if err != nil {
+		hookWarn(err)
+		return
+	}
+
+	out = append(out,
+		shellExport(shell, envKubeconfig, kubeconfig),
+		shellExport(shell, envEntry, entry.QualifiedName()),
+		shellExport(shell, envDirFile, path),
+		shellExport(shell, envDirHash, hash),
+		shellExport(shell, envDirPrevious, previous),
+		shellExport(shell, envDirPreviousEntry, previousEntry),
+		shellUnset(shell, envDirBlocked),
+	)
+	if protected {
+		fmt.Fprintf(os.Stderr, "kubeconfig: WARNING: using PROTECTED entry %q (from %s)\n", regs.DisplayName(entry), path)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "kubeconfig: using entry %q (from %s)\n", regs.DisplayName(entry), path)
+}
+
+// restoreStatements returns statements restoring ${KUBECONFIG} and ${KUBECONFIG_ENTRY} of the session to the values
+// from before entering a directory with the '.kubeconfig-entry' file.
+func restoreStatements(shell string, previous string, previousEntry string) []string {
+	out := []string{}
+	if strings.HasPrefix(previous, previousSetPrefix) {
+		out = append(out, shellExport(shell, envKubeconfig, strings.TrimPrefix(previous, previousSetPrefix)))
+	} else {
+		out = append(out, shellUnset(shell, envKubeconfig))
+	}
+	if previousEntry != "" {
+		out = append(out, shellExport(shell, envEntry, previousEntry))
+	} else {
+		out = append(out, shellUnset(shell, envEntry))
+	}
+	return append(out,
+		shellUnset(shell, envDirFile),
+		shellUnset(shell, envDirHash),
+		shellUnset(shell, envDirPrevious),
+		shellUnset(shell, envDirPreviousEntry),
+	)
+}
+
+func hookWarn(err error) {
+	fmt.Fprintf(os.Stderr, "kubeconfig: %v\n", err)
+}
+
+// newAllowCmd generates a new allow command.
+func newAllowCmd(global *rootOpts) *cobra.Command {
+	protected := false
+
+	cmd := &cobra.Command{
+		Use:   "allow [path]",
+		Short: "Trust a .kubeconfig-entry file",
+		Long:  `Trusts the '.kubeconfig-entry' file (given by its path or the path of its directory, by default the closest to the current directory) with its current content, so that the shell hook uses it. Files selecting protected entries are used only when trusted with '--protected' flag.`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return allowRun(global, path, true, protected)
+		},
+	}
+
+	cmd.Flags().BoolVar(&protected, "protected", false, "trust the file also when it selects a protected entry")
+
+	return cmd
+}
+
+// newDenyCmd generates a new deny command.
+func newDenyCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "deny [path]",
+		Short:   "Revoke trust of a .kubeconfig-entry file",
+		Long:    `Revokes trust of the '.kubeconfig-entry' file (given by its path or the path of its directory, by default the closest to the current directory).`,
+		Aliases: []string{"disallow"},
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return allowRun(global, path, false, false)
+		},
+	}
+
+	return cmd
+}
+
+func allowRun(g *rootOpts, path string, allow bool, protected bool) error {
+	path, err := resolveEntryFile(path)
+	if err != nil {
+		return err
+	}
+
+	if !allow {
+		changed := false
+		err := state.Update(func(s *state.State) error {
+			changed = s.Deny(path)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmt.Printf("File %q is no longer trusted.\n", path)
+		} else {
+			fmt.Printf("File %q was not trusted.\n", path)
+		}
+		return nil
+	}
+
+	ef, hash, err := readEntryFile(path)
+	if err != nil {
+		return err
+	}
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	entry, err := regs.Lookup(ef.Entry)
+	if err != nil {
+		return err
+	}
+	if !protected {
+		ok, err := isProtected(g, regs, entry)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return fmt.Errorf("file %q selects protected entry %q; If that is intended allow it with '--protected' flag", path, regs.DisplayName(entry))
+		}
+	}
+	err = state.Update(func(s *state.State) error {
+		s.Allow(path, hash, protected)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("File %q (selecting entry %q) is trusted.\n", path, ef.Entry)
+	return nil
+}
@@ -0,0 +1,587 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
+)
+
+const doctorLong = `Checks the setup of kubeconfig and reports problems together with ways to fix
+them:
+
+  - resolution of the home, settings and state directories,
+  - existence, permissions and format of registries,
+  - the active kubectl config (whether it exists and is known to kubeconfig),
+  - availability of the editor,
+  - backups exceeding the 'backup.keep' setting,
+  - entries with identical content,
+  - files accessible by other users and broken symbolic links,
+  - shell integration (the ${KUBECONFIG} variable and the shell hook).
+
+With '--fix', safe repairs are made (creating missing registry directories and
+restricting file modes). Nothing is ever removed - backups exceeding the limit
+and broken symbolic links (whose targets may be just unavailable, e.g. on an
+unmounted volume) are only reported. Other problems have to be fixed manually.
+
+Exits with a non-zero code, when problems (not just warnings) remain.`
+
+// Statuses of doctor checks.
+const (
+	doctorOK      = "ok"
+	doctorInfo    = "info"
+	doctorWarning = "warning"
+	doctorProblem = "problem"
+	doctorFixed   = "fixed"
+)
+
+// insecureMode are permission bits, that make files accessible by other users (or writable by the group).
+const insecureMode fs.FileMode = 0027
+
+// newDoctorCmd generates a new doctor command.
+func newDoctorCmd(global *rootOpts) *cobra.Command {
+	o := &doctorOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose problems with the setup",
+		Long:  doctorLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			ui.DisplayAndExitOnError(doctorRun(global, o))
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.fix, "fix", false, "make safe repairs of found problems")
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format (text, json or yaml), instead of the one set in settings")
+
+	return cmd
+}
+
+type doctorOpts struct {
+	fix    bool
+	output string
+}
+
+type doctorItem struct {
+	Check   string `json:"check" yaml:"check"`
+	Status  string `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+	Hint    string `json:"hint,omitempty" yaml:"hint,omitempty"`
+	Fixable bool   `json:"fixable,omitempty" yaml:"fixable,omitempty"`
+
+	fix func() error
+}
+
+func doctorRun(g *rootOpts, o *doctorOpts) error {
+	out := o.output
+	if out == "" {
+		out = settings.OutputText // broken settings are reported as a problem
+		if cfg, err := g.settings(); err == nil {
+			out = cfg.Value("output")
+		}
+	}
+	switch out {
+	case settings.OutputText, settings.OutputJSON, settings.OutputYAML:
+	default:
+		return fmt.Errorf("unknown output format %q", out)
+	}
+
+	items := doctorChecks(g)
+	for i := range items {
+		it := &items[i]
+		it.Fixable = it.fix != nil
+		if !o.fix || it.fix == nil {
+			continue
+		}
+		if err := it.fix(); err != nil {
+			it.Message += fmt.Sprintf(" (fix failed: %v)", err)
+			continue
+		}
+		it.Status, it.Hint, it.Fixable = doctorFixed, "", false
+	}
+
+	problems, warnings, fixable := 0, 0, 0
+	for _, it := range items {
+		switch it.Status {
+		case doctorProblem:
+			problems++
+		case doctorWarning:
+			warnings++
+		}
+		if it.Fixable {
+			fixable++
+		}
+	}
+
+	if out != settings.OutputText {
+		if err := ui.PrintStructured(out, items); err != nil {
+			return err
+		}
+	} else {
+		for _, it := range items {
+			fmt.Printf("%s %s: %s\n", doctorMark(it.Status), it.Check, it.Message)
+			if it.Hint != "" {
+				fmt.Printf("    %s\n", it.Hint)
+			}
+		}
+		fmt.Println()
+		switch {
+		case problems == 0 && warnings == 0:
+			fmt.Println("No problems found.")
+		case fixable > 0:
+			fmt.Printf("Found %d problems and %d warnings; %d of them can be repaired with 'kubeconfig doctor --fix'.\n", problems, warnings, fixable)
+		default:
+			fmt.Printf("Found %d problems and %d warnings.\n", problems, warnings)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %d problems", problems)
+	}
+	return nil
+}
+
+func doctorMark(status string) string {
+	switch status {
+	case doctorOK:
+		return ui.Colorize("green", "✓")
+	case doctorFixed:
+		return ui.Colorize("green", "✓ fixed")
+	case doctorWarning:
+		return ui.Colorize("yellow", "!")
+	case doctorProblem:
+		return ui.Colorize("red", "✗")
+	}
+	return ui.Colorize("faint", "-")
+}
+
+// doctorChecks runs all checks. Checks depending on settings or registries are skipped, when these cannot be loaded.
+func doctorChecks(g *rootOpts) []doctorItem {
+	items, ok := doctorEnvironment(g)
+	if !ok {
+		return items
+	}
+	cfg, _ := g.settings()
+
+	regs, err := g.registries()
+	if err != nil {
+		item := doctorItem{Check: "registries", Status: doctorProblem, Message: err.Error()}
+		if !errors.Is(err, registry.ErrNewerFormat) {
+			item.Hint = "fix the 'registries' and 'writeRegistry' settings (see 'kubeconfig settings list')"
+		}
+		items = append(items, item)
+	} else {
+		for _, s := range regs.Sources() {
+			items = append(items, doctorRegistry(s)...)
+		}
+		items = append(items, doctorActive(g, regs)...)
+		items = append(items, doctorDuplicates(regs)...)
+	}
+
+	items = append(items, doctorEditor(g))
+	items = append(items, doctorBackups(cfg.BackupKeep())...)
+	if regs != nil {
+		items = append(items, doctorShell(regs)...)
+	}
+	return items
+}
+
+// doctorEnvironment checks the resolution of directories used by kubeconfig. It reports whether settings were loaded.
+func doctorEnvironment(g *rootOpts) ([]doctorItem, bool) {
+	const check = "environment"
+	home, err := settings.ExpandPath("~")
+	if err != nil {
+		return []doctorItem{{Check: check, Status: doctorProblem, Message: err.Error(), Hint: "set the ${HOME} environment variable to your home directory"}}, false
+	}
+	items := []doctorItem{{Check: check, Status: doctorOK, Message: fmt.Sprintf("home directory %q", home)}}
+
+	path, err := settings.Path()
+	if err != nil {
+		return append(items, doctorItem{Check: check, Status: doctorProblem, Message: err.Error()}), false
+	}
+	if _, err := g.settings(); err != nil {
+		return append(items, doctorItem{Check: "settings", Status: doctorProblem, Message: err.Error(), Hint: fmt.Sprintf("fix the settings file %q (or the environment variable named above)", path)}), false
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		items = append(items, doctorItem{Check: "settings", Status: doctorOK, Message: fmt.Sprintf("no settings file %q, defaults are used", path)})
+	} else {
+		items = append(items, doctorItem{Check: "settings", Status: doctorOK, Message: fmt.Sprintf("settings file %q", path)})
+	}
+
+	dir, err := settings.StateDir()
+	if err != nil {
+		return append(items, doctorItem{Check: check, Status: doctorProblem, Message: err.Error()}), true
+	}
+	switch _, err := os.Stat(dir); {
+	case errors.Is(err, fs.ErrNotExist):
+		items = append(items, doctorItem{Check: check, Status: doctorOK, Message: fmt.Sprintf("state directory %q (not created yet)", dir)})
+	case err != nil:
+		items = append(items, doctorItem{Check: check, Status: doctorProblem, Message: err.Error()})
+	default:
+		if err := dirWritable(dir); err != nil {
+			items = append(items, doctorItem{Check: check, Status: doctorProblem, Message: fmt.Sprintf("state directory %q is not writable, so backups and usage history cannot be stored", dir), Hint: fmt.Sprintf("grant yourself access, e.g. with 'chmod u+rwx %s'", dir)})
+		} else {
+			items = append(items, doctorItem{Check: check, Status: doctorOK, Message: fmt.Sprintf("state directory %q", dir)})
+		}
+	}
+	return items, true
+}
+
+// doctorRegistry checks existence, permissions and format of the given registry, and files stored in it.
+func doctorRegistry(s *registry.Source) []doctorItem {
+	check := fmt.Sprintf("registry %q", s.Name)
+	stat, err := os.Stat(s.Path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if s.ReadOnly {
+			return []doctorItem{{Check: check, Status: doctorWarning, Message: fmt.Sprintf("directory %q does not exist", s.Path), Hint: "clone or mount the registry, or remove it from the 'registries' setting"}}
+		}
+		return []doctorItem{{Check: check, Status: doctorWarning, Message: fmt.Sprintf("directory %q does not exist", s.Path), Hint: "it is created when the first entry is saved or added", fix: func() error { return registry.Init(s) }}}
+	case err != nil:
+		return []doctorItem{{Check: check, Status: doctorProblem, Message: err.Error()}}
+	case !stat.IsDir():
+		return []doctorItem{{Check: check, Status: doctorProblem, Message: fmt.Sprintf("%q is not a directory", s.Path), Hint: "move the file away or change the registry path in settings"}}
+	}
+	if _, err := os.ReadDir(s.Path); err != nil {
+		return []doctorItem{{Check: check, Status: doctorProblem, Message: fmt.Sprintf("directory %q is not readable", s.Path), Hint: fmt.Sprintf("grant yourself access, e.g. with 'chmod u+rwx %s'", s.Path)}}
+	}
+	if !s.ReadOnly {
+		if err := dirWritable(s.Path); err != nil {
+			return []doctorItem{{Check: check, Status: doctorProblem, Message: fmt.Sprintf("directory %q is not writable", s.Path), Hint: fmt.Sprintf("grant yourself access, e.g. with 'chmod u+rwx %s' (or mark the registry as read-only in settings)", s.Path)}}
+		}
+	}
+
+	var items []doctorItem
+	m, err := registry.ReadRegistryManifest(s.Path)
+	if err != nil {
+		return []doctorItem{{Check: check, Status: doctorProblem, Message: err.Error()}}
+	}
+	steps, err := registry.PendingMigrations(s)
+	switch {
+	case err != nil:
+		items = append(items, doctorItem{Check: check, Status: doctorProblem, Message: err.Error()})
+	case len(steps) > 0 && m.Format == 0:
+		items = append(items, doctorItem{Check: check, Status: doctorWarning, Message: fmt.Sprintf("directory %q is a legacy plain-directory registry", s.Path), Hint: "upgrade it with 'kubeconfig migrate'"})
+	case len(steps) > 0:
+		items = append(items, doctorItem{Check: check, Status: doctorWarning, Message: fmt.Sprintf("directory %q uses format version %d (current is %d)", s.Path, m.Format, registry.FormatVersion), Hint: "upgrade it with 'kubeconfig migrate'"})
+	default:
+		msg := fmt.Sprintf("directory %q, format version %d", s.Path, m.Format)
+		if s.ReadOnly {
+			msg += ", read-only"
+		}
+		items = append(items, doctorItem{Check: check, Status: doctorOK, Message: msg})
+	}
+	return append(items, doctorRegistryFiles(s)...)
+}
+
+// doctorRegistryFiles checks files of the given registry for insecure modes and broken symbolic links. Files of
+// read-only registries are not modified.
+func doctorRegistryFiles(s *registry.Source) []doctorItem {
+	check := fmt.Sprintf("registry %q", s.Name)
+	var insecure, broken []string
+	filepath.WalkDir(s.Path, func(path string, d fs.DirEntry, err error) error { //nolint:errcheck
+		if err != nil {
+			return nil // unreadable directories are skipped
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			if _, err := os.Stat(path); err != nil {
+				broken = append(broken, path)
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && runtime.GOOS != "windows" {
+			if info, err := d.Info(); err == nil && info.Mode().Perm()&insecureMode != 0 {
+				insecure = append(insecure, path)
+			}
+		}
+		return nil
+	})
+
+	var items []doctorItem
+	if len(insecure) > 0 && !s.ReadOnly {
+		items = append(items, doctorItem{
+			Check:   check,
+			Status:  doctorWarning,
+			Message: fmt.Sprintf("%d files are accessible by other users: %s", len(insecure), doctorRelPaths(s.Path, insecure)),
+			Hint:    "restrict their modes, e.g. with 'chmod o-rwx,g-w'",
+			fix:     func() error { return restrictModes(insecure) },
+		})
+	}
+	if len(broken) > 0 {
+		items = append(items, doctorItem{
+			Check:   check,
+			Status:  doctorWarning,
+			Message: fmt.Sprintf("%d broken symbolic links: %s", len(broken), doctorRelPaths(s.Path, broken)),
+			Hint:    "restore their targets (e.g. mount the volume they point to) or remove them manually",
+		})
+	}
+	return items
+}
+
+// doctorActive checks the active kubectl config.
+func doctorActive(g *rootOpts, regs *registry.Registries) []doctorItem {
+	const check = "kubectl config"
+	path, err := g.kubectlConfigPath()
+	if err != nil {
+		return []doctorItem{{Check: check, Status: doctorProblem, Message: err.Error(), Hint: "fix the 'kubectlConfig' setting"}}
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		if lstat, lerr := os.Lstat(path); lerr == nil && lstat.Mode()&fs.ModeSymlink != 0 {
+			return []doctorItem{{Check: check, Status: doctorProblem, Message: fmt.Sprintf("%q is a broken symbolic link", path), Hint: "remove it or restore its target, then switch to an entry with 'kubeconfig switch'"}}
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return []doctorItem{{Check: check, Status: doctorWarning, Message: fmt.Sprintf("%q does not exist", path), Hint: "switch to an entry with 'kubeconfig switch'"}}
+		}
+		return []doctorItem{{Check: check, Status: doctorProblem, Message: err.Error()}}
+	}
+
+	var items []doctorItem
+	if runtime.GOOS != "windows" && stat.Mode().Perm()&insecureMode != 0 {
+		items = append(items, doctorItem{
+			Check:   check,
+			Status:  doctorWarning,
+			Message: fmt.Sprintf("%q is accessible by other users (mode %s)", path, stat.Mode().Perm()),
+			Hint:    fmt.Sprintf("restrict its mode, e.g. with 'chmod o-rwx,g-w %s'", path),
+			fix:     func() error { return restrictModes([]string{path}) },
+		})
+	}
+
+	hash, err := registry.Hash(path)
+	if err != nil {
+		return append(items, doctorItem{Check: check, Status: doctorProblem, Message: err.Error()})
+	}
+	current, found, err := regs.Find(hash)
+	switch {
+	case err != nil:
+		items = append(items, doctorItem{Check: check, Status: doctorProblem, Message: err.Error()})
+	case !found:
+		items = append(items, doctorItem{Check: check, Status: doctorWarning, Message: fmt.Sprintf("%q is not in the registry", path), Hint: "save it with 'kubeconfig save <name>', so that it is not lost when switching"})
+	default:
+		items = append(items, doctorItem{Check: check, Status: doctorOK, Message: fmt.Sprintf("%q is entry %q", path, regs.DisplayName(current))})
+	}
+	return items
+}
+
+// doctorDuplicates reports entries with identical content.
+func doctorDuplicates(regs *registry.Registries) []doctorItem {
+	const check = "entries"
+	ls, err := regs.List(nil)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil // missing registries are reported by their checks
+	}
+	if err != nil {
+		return []doctorItem{{Check: check, Status: doctorProblem, Message: err.Error()}}
+	}
+
+	var hashes []string
+	groups := map[string][]string{}
+	for _, e := range ls {
+		h, err := registry.Hash(e.Path)
+		if err != nil {
+			continue
+		}
+		if _, ok := groups[string(h)]; !ok {
+			hashes = append(hashes, string(h))
+		}
+		groups[string(h)] = append(groups[string(h)], fmt.Sprintf("%q", regs.DisplayName(e)))
+	}
+
+	var items []doctorItem
+	for _, h := range hashes {
+		if names := groups[h]; len(names) > 1 {
+			items = append(items, doctorItem{Check: check, Status: doctorWarning, Message: fmt.Sprintf("entries %s have identical content", strings.Join(names, ", ")), Hint: "remove redundant ones (e.g. with 'd' in 'kubeconfig tui')"})
+		}
+	}
+	if len(items) == 0 {
+		items = append(items, doctorItem{Check: check, Status: doctorOK, Message: fmt.Sprintf("%d entries, no duplicates", len(ls))})
+	}
+	return items
+}
+
+// doctorEditor checks whether the editor can be found.
+func doctorEditor(g *rootOpts) doctorItem {
+	const check = "editor"
+	editor, err := g.editor("")
+	if err != nil {
+		return doctorItem{Check: check, Status: doctorWarning, Message: "no editor set, so 'kubeconfig edit' cannot be used", Hint: "set the 'editor' setting (e.g. 'kubeconfig settings set editor vim') or the ${EDITOR} environment variable"}
+	}
+	path, err := exec.LookPath(editor)
+	if err != nil {
+		return doctorItem{Check: check, Status: doctorProblem, Message: fmt.Sprintf("editor %q not found", editor), Hint: "install it or change the 'editor' setting (or the ${EDITOR} environment variable)"}
+	}
+	return doctorItem{Check: check, Status: doctorOK, Message: fmt.Sprintf("editor %q (%s)", editor, path)}
+}
+
+// doctorBackups reports backups exceeding the keep limit (e.g. after it was lowered).
+func doctorBackups(keep int) []doctorItem {
+	const check = "backups"
+	dir, err := settings.BackupDir()
+	if err != nil {
+		return []doctorItem{{Check: check, Status: doctorProblem, Message: err.Error()}}
+	}
+
+	total := 0
+	stale := map[string]int{}
+	var names []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error { //nolint:errcheck
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		ls, err := registry.ListBackups(dir, filepath.ToSlash(name))
+		if err != nil {
+			return nil
+		}
+		total += len(ls)
+		if len(ls) > keep {
+			stale[name] = len(ls) - keep
+			names = append(names, filepath.ToSlash(name))
+		}
+		return nil
+	})
+
+	if len(names) == 0 {
+		return []doctorItem{{Check: check, Status: doctorOK, Message: fmt.Sprintf("%d backups in %q", total, dir)}}
+	}
+	n := 0
+	for _, c := range stale {
+		n += c
+	}
+	if keep == 0 {
+		return []doctorItem{{Check: check, Status: doctorInfo, Message: fmt.Sprintf("backups are disabled, but %d backups are stored in %q", n, dir), Hint: "remove the directory, if they are not needed"}}
+	}
+	return []doctorItem{{
+		Check:   check,
+		Status:  doctorWarning,
+		Message: fmt.Sprintf("%d backups exceed the 'backup.keep' limit of %d (of %s)", n, keep, strings.Join(names, ", ")),
+		Hint:    fmt.Sprintf("remove the oldest ones from %q manually (they are also removed when the next backup of the same kind is made)", dir),
+	}}
+}
+
+// initHookRegexp matches lines of shell startup files enabling the shell hook.
+var initHookRegexp = regexp.MustCompile(`kubeconfig(\.exe)?['"]?\s+init\b`)
+
+// doctorShell checks the shell integration - the ${KUBECONFIG} variable and the shell hook.
+func doctorShell(regs *registry.Registries) []doctorItem {
+	const check = "shell"
+	var items []doctorItem
+
+	if v := os.Getenv(envKubeconfig); v != "" {
+		for _, p := range filepath.SplitList(v) {
+			if _, err := os.Stat(p); err != nil {
+				items = append(items, doctorItem{Check: check, Status: doctorProblem, Message: fmt.Sprintf("${%s} names file %q, that cannot be read", envKubeconfig, p), Hint: "unset it (e.g. with 'eval \"$(kubeconfig env --unset)\"')"})
+			}
+		}
+		if e, ok, err := sessionEntry(regs); err != nil {
+			items = append(items, doctorItem{Check: check, Status: doctorProblem, Message: err.Error(), Hint: "unset it (e.g. with 'eval \"$(kubeconfig env --unset)\"')"})
+		} else if ok {
+			items = append(items, doctorItem{Check: check, Status: doctorOK, Message: fmt.Sprintf("the shell session uses entry %q", regs.DisplayName(e))})
+		} else {
+			items = append(items, doctorItem{Check: check, Status: doctorWarning, Message: fmt.Sprintf("${%s} is set to %q, so kubectl ignores the config switched by kubeconfig", envKubeconfig, v), Hint: "unset it in your shell startup files (and use 'kubeconfig env' to select entries per session)"})
+		}
+	}
+
+	shell := detectShell()
+	files, example := shellStartupFiles(shell)
+	if len(files) == 0 {
+		return append(items, doctorItem{Check: check, Status: doctorInfo, Message: fmt.Sprintf("cannot tell whether the shell hook is enabled for %s", shell), Hint: "see 'kubeconfig init --help'"})
+	}
+	for _, f := range files {
+		if b, err := os.ReadFile(f); err == nil && initHookRegexp.Match(b) {
+			return append(items, doctorItem{Check: check, Status: doctorOK, Message: fmt.Sprintf("shell hook enabled in %q", f)})
+		}
+	}
+	return append(items, doctorItem{Check: check, Status: doctorInfo, Message: fmt.Sprintf("shell hook not enabled for %s, so '%s' files are not used", shell, entryFileName), Hint: fmt.Sprintf("to use them, add '%s' to %q", example, files[0])})
+}
+
+// shellStartupFiles returns startup files of the given shell, that may enable the shell hook, and the line enabling it.
+// PowerShell profiles are not known.
+func shellStartupFiles(shell string) ([]string, string) {
+	home, err := settings.ExpandPath("~")
+	if err != nil {
+		return nil, ""
+	}
+	switch shell {
+	case shellBash:
+		return []string{filepath.Join(home, ".bashrc"), filepath.Join(home, ".bash_profile"), filepath.Join(home, ".profile")}, `eval "$(kubeconfig init bash)"`
+	case shellZsh:
+		dir := os.Getenv("ZDOTDIR")
+		if dir == "" {
+			dir = home
+		}
+		return []string{filepath.Join(dir, ".zshrc"), filepath.Join(dir, ".zprofile")}, `eval "$(kubeconfig init zsh)"`
+	case shellFish:
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			dir = filepath.Join(home, ".config")
+		}
+		return []string{filepath.Join(dir, "fish", "config.fish")}, "kubeconfig init fish | source"
+	}
+	return nil, ""
+}
+
+// dirWritable returns an error, when files cannot be created in the given directory. The probe file is named like
+// temporary files of registries, so that it is never listed as an entry.
+func dirWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".tmp-doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// restrictModes removes permission bits making the given files accessible by other users.
+func restrictModes(paths []string) error {
+	for _, p := range paths {
+		stat, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(p, stat.Mode().Perm()&^insecureMode); err != nil {
+			return fmt.Errorf("cannot change mode of file %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func doctorRelPaths(root string, paths []string) string {
+	res := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if rel, err := filepath.Rel(root, p); err == nil {
+			p = rel
+		}
+		res = append(res, fmt.Sprintf("%q", p))
+	}
+	return strings.Join(res, ", ")
+}
@@ -15,8 +15,10 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 
@@ -27,8 +29,8 @@ import (
 )
 
 const editLong = `Enables editition of a new kubectl config file to kubeconfig registry, by
-creating a new empty file with the provided name and opening editor described by
-the ${EDITOR} environment variable.
+creating a new empty file with the provided name and opening editor set in
+settings or described by the ${EDITOR} environment variable.
 
 If the kubectl config file is not specified, the command presents an interactive
 list of all files in the registry with an option to select one.`
@@ -53,7 +55,9 @@ func newEditCmd(global *rootOpts) *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&o.editor, "editor", "e", "", "sets the editor used directly, instead of using the ${EDITOR} environment variable")
+	cmd.Flags().StringVarP(&o.editor, "editor", "e", "", "sets the editor used directly, instead of using the one from settings or the ${EDITOR} environment variable")
+	cmd.Flags().BoolVar(&o.tree, "tree", false, "present entries in the interactive prompt as a hierarchy of folders")
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "filter entries presented in the interactive prompt by labels (e.g. 'env=prod,team!=infra')")
 
 	return cmd
 }
@@ -61,44 +65,110 @@ func newEditCmd(global *rootOpts) *cobra.Command {
 type editOpts struct {
 	name        string
 	editor      string
+	selector    string
+	tree        bool
 	interactive bool
 }
 
 func editRun(ctx context.Context, g *rootOpts, o *editOpts) {
-	editor := o.editor
-	if editor == "" {
-		e, ok := os.LookupEnv("EDITOR")
-		if !ok {
-			ui.DisplayAndExitOnError(fmt.Errorf("$EDITOR environment vatiabble not set"))
-		}
-		editor = e
-	}
+	editor, err := g.editor(o.editor)
+	ui.DisplayAndExitOnError(err)
 
-	reg, err := g.regPath()
+	regs, err := g.registries()
 	ui.DisplayAndExitOnError(err)
 
-	path := ""
+	var entry registry.Entry
 	if o.interactive {
-		kcCfgPath, err := registry.KubectlConfigPath()
+		entry, err = selectEntry(g, regs, "Which kubectl config file to edit", o.selector, o.tree)
 		ui.DisplayAndExitOnError(err)
-
-		kcCfgHash, err := registry.Hash(kcCfgPath)
+	} else {
+		entry, err = regs.Lookup(o.name)
 		ui.DisplayAndExitOnError(err)
+	}
 
-		path, err = ui.SelectPrompt(reg, "Which kubectl config file to edit", kcCfgHash)
-		ui.DisplayAndExitOnError(err)
-	} else {
-		path = registry.NameToPath(reg, o.name)
+	_, err = editEntry(ctx, g, regs, entry, editor)
+	ui.DisplayAndExitOnError(err)
+}
+
+// editEntry opens a temporary copy of the given entry in the editor and writes the modified content back (moving the
+// previous one to the trash), running edit hooks around writing. It reports whether the entry was modified.
+func editEntry(ctx context.Context, g *rootOpts, regs *registry.Registries, entry registry.Entry, editor string) (bool, error) {
+	if err := regs.CheckWritable(entry); err != nil {
+		return false, lockHint(err)
+	}
+
+	// edit a temporary copy, so that the modified content is written back through the registry
+	tmpPath, err := tempCopy(entry.Path)
+	if err != nil {
+		return false, err
 	}
+	defer os.Remove(tmpPath)
 
+	if err := runEditor(ctx, editor, tmpPath); err != nil {
+		return false, err
+	}
+
+	oldHash, err := registry.Hash(entry.Path)
+	if err != nil {
+		return false, err
+	}
+	newHash, err := registry.Hash(tmpPath)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(oldHash, newHash) {
+		return false, nil
+	}
+
+	tmp, err := registry.Read(tmpPath)
+	if err != nil {
+		return false, err
+	}
+	defer tmp.Close()
+
+	hook := hookEnv{to: entry.QualifiedName(), toPath: entry.Path}
+	if err := g.runHook(ctx, hookPreEdit, hook); err != nil {
+		return false, err
+	}
+	if err := regs.Write(entry, tmp, true); err != nil {
+		return false, lockHint(err)
+	}
+	g.runPostHook(ctx, hookPostEdit, hook)
+	return true, nil
+}
+
+// runEditor opens the given file in the editor and waits for it to finish.
+func runEditor(ctx context.Context, editor string, path string) error {
 	cmd := exec.CommandContext(ctx, editor, path)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Start(); err != nil {
-		ui.DisplayAndExitOnError(fmt.Errorf("starting editor %q failed: %w", editor, err))
+		return fmt.Errorf("starting editor %q failed: %w", editor, err)
 	}
 	if err := cmd.Wait(); err != nil {
-		ui.DisplayAndExitOnError(fmt.Errorf("editor %q failed: %w", editor, err))
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
+
+// tempCopy creates a temporary copy of the given file and returns its path.
+func tempCopy(path string) (string, error) {
+	src, err := registry.Read(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp("", "*.config")
+	if err != nil {
+		return "", fmt.Errorf("cannot create temporary file: %w", err)
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("writing to file %q filed: %w", tmp.Name(), err)
 	}
+	return tmp.Name(), nil
 }
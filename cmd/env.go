@@ -0,0 +1,136 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/registry"
+)
+
+const envLong = `Prints statements setting ${KUBECONFIG} to a private copy of the entry, to be
+evaluated by the shell - the active kubectl config file is not touched:
+
+  eval "$(kubeconfig env aws/prod)"                   # bash, zsh
+  kubeconfig env aws/prod | source                     # fish
+  kubeconfig env aws/prod | Out-String | Invoke-Expression  # PowerShell
+
+The shell is detected from the environment, unless set with '--shell'. With
+'--export-entry', ${KUBECONFIG_ENTRY} is also set, so that prompts and
+'kubeconfig current' can report the entry name. '--unset' prints statements
+removing both variables.
+
+Protected entries require confirmation with '--yes' (the output is evaluated by
+the shell, so there is no interactive prompt).`
+
+// newEnvCmd generates a new env command.
+func newEnvCmd(global *rootOpts) *cobra.Command {
+	o := &envOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "env [config name]",
+		Short: "Print statements setting KUBECONFIG to an entry",
+		Long:  envLong,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				o.name = args[0]
+			}
+			return envRun(global, o)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.shell, "shell", "", "shell syntax (bash, zsh, fish or powershell), instead of the detected one")
+	cmd.Flags().BoolVar(&o.unset, "unset", false, "print statements removing the variables instead")
+	cmd.Flags().BoolVar(&o.exportEntry, "export-entry", false, "set also ${KUBECONFIG_ENTRY} to the entry name")
+	cmd.Flags().StringVar(&o.context, "context", "", "set the current context of the copy")
+	cmd.Flags().StringVarP(&o.namespace, "namespace", "n", "", "set the namespace of the current context of the copy")
+	cmd.Flags().BoolVarP(&o.yes, "yes", "y", false, protectedYesUsage)
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "show only entries matching the label selector in the interactive prompt (e.g. 'env=prod')")
+
+	return cmd
+}
+
+type envOpts struct {
+	name        string
+	shell       string
+	unset       bool
+	exportEntry bool
+	context     string
+	namespace   string
+	selector    string
+	yes         bool
+}
+
+func envRun(g *rootOpts, o *envOpts) error {
+	shell, err := resolveShell(o.shell)
+	if err != nil {
+		return err
+	}
+
+	if o.unset {
+		if o.name != "" {
+			return fmt.Errorf("no config name expected with '--unset' flag")
+		}
+		fmt.Println(shellUnset(shell, envKubeconfig))
+		fmt.Println(shellUnset(shell, envEntry))
+		return nil
+	}
+
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	var entry registry.Entry
+	if o.name == "" {
+		entry, err = selectEntry(g, regs, "Which kubectl config file to use", o.selector, false)
+	} else {
+		entry, err = regs.Lookup(o.name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := guardProtected(g, regs, entry, o.yes); err != nil {
+		return err
+	}
+	path, err := materialize(entry, o.context, o.namespace)
+	if err != nil {
+		return err
+	}
+	out := []string{shellExport(shell, envKubeconfig, path)}
+	if o.exportEntry {
+		out = append(out, shellExport(shell, envEntry, entry.QualifiedName()))
+	}
+	fmt.Println(strings.Join(out, "\n"))
+	return nil
+}
+
+// sessionEntry returns the entry the shell session uses (set by 'kubeconfig env' or the directory hook), if any.
+func sessionEntry(regs *registry.Registries) (registry.Entry, bool, error) {
+	name := os.Getenv(envEntry)
+	if name == "" {
+		return registry.Entry{}, false, nil
+	}
+	e, err := regs.Lookup(name)
+	if err != nil {
+		return registry.Entry{}, false, fmt.Errorf("entry set by ${%s}: %w", envEntry, err)
+	}
+	return e, true, nil
+}
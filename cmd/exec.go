@@ -0,0 +1,290 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/kubecfg"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
+)
+
+const execPluginsLong = `Lists exec based credential plugins (e.g. aws, gke-gcloud-auth-plugin,
+kubelogin) used by users of entries (all of them, the given ones or ones
+matching the label selector), checks whether their binaries are available in
+PATH and whether their apiVersion is supported by current kubectl releases.`
+
+// newExecPluginsCmd generates a new exec-plugins command.
+func newExecPluginsCmd(global *rootOpts) *cobra.Command {
+	o := &execPluginsOpts{}
+
+	cmd := &cobra.Command{
+		Use:     "exec-plugins [names...]",
+		Short:   "Show credential plugins used by entries",
+		Long:    execPluginsLong,
+		Aliases: []string{"credential-plugins"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.names = args
+			return execPluginsRun(global, o)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format (text, json or yaml), instead of the one set in settings")
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "show only entries matching the label selector (e.g. 'provider=eks')")
+
+	return cmd
+}
+
+type execPluginsOpts struct {
+	names    []string
+	output   string
+	selector string
+}
+
+type execPluginItem struct {
+	Name        string `json:"name" yaml:"name"`
+	Registry    string `json:"registry" yaml:"registry"`
+	User        string `json:"user" yaml:"user"`
+	Command     string `json:"command" yaml:"command"`
+	APIVersion  string `json:"apiVersion" yaml:"apiVersion"`
+	Supported   bool   `json:"supported" yaml:"supported"`
+	Path        string `json:"path,omitempty" yaml:"path,omitempty"`
+	InstallHint string `json:"installHint,omitempty" yaml:"installHint,omitempty"`
+}
+
+func execPluginsRun(g *rootOpts, o *execPluginsOpts) error {
+	out, err := g.output(o.output)
+	if err != nil {
+		return err
+	}
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	entries, err := lookupOrList(regs, o.names, o.selector)
+	if err != nil {
+		return err
+	}
+	dir, err := pluginBaseDir(g)
+	if err != nil {
+		return err
+	}
+
+	items := []execPluginItem{}
+	for _, e := range entries {
+		cfg, err := kubecfg.ReadFile(e.Path)
+		if err != nil {
+			continue // not a valid kubectl config, so it has no plugins
+		}
+		for _, u := range cfg.Users {
+			x := u.User.Exec
+			if x == nil {
+				continue
+			}
+			item := execPluginItem{Name: regs.DisplayName(e), Registry: e.Source.Name, User: u.Name, Command: x.Command, APIVersion: x.APIVersion, Supported: x.SupportedAPIVersion()}
+			if path, err := x.LookPath(dir); err == nil {
+				item.Path = path
+			} else {
+				item.InstallHint = x.InstallHint
+			}
+			items = append(items, item)
+		}
+	}
+
+	if out != settings.OutputText {
+		return ui.PrintStructured(out, items)
+	}
+	if len(items) == 0 {
+		fmt.Println("No entries use credential plugins.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ENTRY\tUSER\tCOMMAND\tAPI VERSION\tBINARY")
+	for _, i := range items {
+		apiVersion := i.APIVersion
+		if !i.Supported {
+			apiVersion += " (unsupported)"
+		}
+		binary := i.Path
+		if binary == "" {
+			binary = ui.Colorize("red", "not found in PATH")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", i.Name, i.User, i.Command, apiVersion, binary)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, i := range items {
+		if i.Path == "" && i.InstallHint != "" {
+			fmt.Printf("\n%s (%s):\n%s\n", i.Command, i.Name, i.InstallHint)
+		}
+	}
+	return nil
+}
+
+// pluginBaseDir returns the directory relative credential plugin commands of entries are resolved against - the one of
+// the kubectl config, where entries are used once switched to.
+func pluginBaseDir(g *rootOpts) (string, error) {
+	kcCfgPath, err := g.kubectlConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(kcCfgPath), nil
+}
+
+// lookupOrList returns entries with the given names or, if none are given, all entries matching the label selector.
+func lookupOrList(regs *registry.Registries, names []string, selector string) ([]registry.Entry, error) {
+	if len(names) == 0 {
+		sel, err := registry.ParseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		return regs.List(sel)
+	}
+	if selector != "" {
+		return nil, fmt.Errorf("names and the label selector are mutually exclusive")
+	}
+	entries := make([]registry.Entry, 0, len(names))
+	for _, n := range names {
+		e, err := regs.Lookup(n)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+const execTestLong = `Invokes the exec based credential plugin of the entry user (by default, the
+user of the current context) the way kubectl does - with the configured
+arguments and environment, and the ExecCredential request in the
+${KUBERNETES_EXEC_INFO} environment variable - and reports the returned
+credential status and expiry. Returned secrets are never printed. Relative
+plugin commands (e.g. './bin/auth') are resolved against the directory of the
+active kubectl config, as kubectl does once the entry is switched to.
+
+Testing a protected entry has to be confirmed (with '--yes' in non-interactive
+use).`
+
+// newExecTestCmd generates a new exec-test command.
+func newExecTestCmd(global *rootOpts) *cobra.Command {
+	o := &execTestOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "exec-test [config name]",
+		Short: "Test the credential plugin of an entry",
+		Long:  execTestLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.name = args[0]
+			return execTestRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.context, "context", "", "test the user of the given context, instead of the current one")
+	cmd.Flags().StringVar(&o.user, "user", "", "test the given user, instead of the user of the current context")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", time.Minute, "time to wait for the plugin")
+	cmd.Flags().BoolVarP(&o.yes, "yes", "y", false, protectedYesUsage)
+
+	return cmd
+}
+
+type execTestOpts struct {
+	name    string
+	context string
+	user    string
+	timeout time.Duration
+	yes     bool
+}
+
+func execTestRun(ctx context.Context, g *rootOpts, o *execTestOpts) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	entry, err := regs.Lookup(o.name)
+	if err != nil {
+		return err
+	}
+	if err := guardProtected(g, regs, entry, o.yes); err != nil {
+		return err
+	}
+	dir, err := pluginBaseDir(g)
+	if err != nil {
+		return err
+	}
+	cfg, err := kubecfg.ReadFile(entry.Path)
+	if err != nil {
+		return err
+	}
+
+	userName, cluster := o.user, (*kubecfg.Cluster)(nil)
+	if userName == "" {
+		ctxName := o.context
+		if ctxName == "" {
+			ctxName = cfg.CurrentContext
+		}
+		c, ok := cfg.Context(ctxName)
+		if !ok {
+			return fmt.Errorf("context %q does not exist", ctxName)
+		}
+		userName = c.Context.User
+		if cl, ok := cfg.Cluster(c.Context.Cluster); ok {
+			cluster = &cl.Cluster
+		}
+	}
+	u, ok := cfg.User(userName)
+	if !ok {
+		return fmt.Errorf("user %q does not exist", userName)
+	}
+	if u.User.Exec == nil {
+		return fmt.Errorf("user %q does not use a credential plugin", userName)
+	}
+
+	x := u.User.Exec
+	interactive := x.InteractiveMode != "Never" && ui.Interactive()
+	fmt.Printf("Running %q (%s) for user %q...\n", x.Command, x.APIVersion, userName)
+
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+	start := time.Now()
+	cred, err := x.Run(ctx, dir, cluster, interactive, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Plugin returned a valid ExecCredential in %s.\n", time.Since(start).Round(time.Millisecond))
+	if cred.Status.Token != "" {
+		fmt.Printf("  token: present (%d characters, redacted)\n", len(cred.Status.Token))
+	}
+	if cred.Status.ClientCertificateData != "" {
+		fmt.Println("  client certificate and key: present (redacted)")
+	}
+	if cred.Status.ExpirationTimestamp != nil {
+		fmt.Printf("  expiry: %s\n", ui.ExpirySummary(*cred.Status.ExpirationTimestamp, time.Now()))
+	} else {
+		fmt.Println("  expiry: not set (credential is not cached by kubectl)")
+	}
+	return nil
+}
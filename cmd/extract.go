@@ -0,0 +1,131 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/kubecfg"
+)
+
+const extractLong = `Extracts a single context (by default, the current one) of the entry together
+with the cluster and the user it references into a new, minimal kubectl config.
+Referenced certificate and key files are embedded (see 'kubeconfig flatten').
+
+The result is written as a new entry with the given name or, with '-o -', to
+the standard output.`
+
+// newExtractCmd generates a new extract command.
+func newExtractCmd(global *rootOpts) *cobra.Command {
+	o := &extractOpts{}
+
+	cmd := &cobra.Command{
+		Use:     "extract [config name]",
+		Short:   "Extract a single context into a new entry",
+		Long:    extractLong,
+		Aliases: []string{"minify"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.name = args[0]
+			return extractRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.context, "context", "", "name of the extracted context, instead of the current context of the entry")
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "name of the new entry ('-' for the standard output)")
+	cmd.Flags().StringVar(&o.baseDir, "base-dir", "", "directory relative paths are resolved against, instead of the directory of the active kubectl config file")
+	cmd.Flags().BoolVarP(&o.force, "force", "f", false, "force override, if the entry with the provided name already exists in the registry")
+	o.expiryOpts.addFlags(cmd.Flags())
+	_ = cmd.MarkFlagRequired("output")
+
+	return cmd
+}
+
+type extractOpts struct {
+	expiryOpts
+	name    string
+	context string
+	output  string
+	baseDir string
+	force   bool
+}
+
+func extractRun(ctx context.Context, g *rootOpts, o *extractOpts) error {
+	expires, err := o.expiry(time.Now())
+	if err != nil {
+		return err
+	}
+
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	entry, err := regs.Lookup(o.name)
+	if err != nil {
+		return err
+	}
+
+	baseDir := o.baseDir
+	if baseDir == "" {
+		kcCfgPath, err := g.kubectlConfigPath()
+		if err != nil {
+			return err
+		}
+		baseDir = filepath.Dir(kcCfgPath)
+	}
+
+	cfg, err := kubecfg.ReadFile(entry.Path)
+	if err != nil {
+		return err
+	}
+	minimal, err := cfg.Minify(o.context)
+	if err != nil {
+		return fmt.Errorf("entry %q: %w", regs.DisplayName(entry), err)
+	}
+	if _, err := flattenConfig(minimal, baseDir); err != nil {
+		return err
+	}
+	b, err := minimal.Encode()
+	if err != nil {
+		return err
+	}
+
+	if o.output == "-" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+
+	target, err := regs.Target(o.output)
+	if err != nil {
+		return err
+	}
+	hook := hookEnv{from: entry.QualifiedName(), fromPath: entry.Path, to: target.QualifiedName(), toPath: target.Path}
+	if err := writeEntry(g, regs, target, bytes.NewReader(b), o.force, g.preHook(ctx, hookPreExtract, hook)); err != nil {
+		return err
+	}
+	if err := setExpiry(regs, target, expires); err != nil {
+		return err
+	}
+	g.runPostHook(ctx, hookPostExtract, hook)
+	fmt.Printf("Context %q extracted from %q to a new entry %q.\n", minimal.CurrentContext, regs.DisplayName(entry), regs.DisplayName(target))
+	return nil
+}
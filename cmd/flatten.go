@@ -0,0 +1,142 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/kubecfg"
+)
+
+const flattenLong = `Embeds certificate and key files referenced by the entry (certificate-authority,
+client-certificate and client-key fields) as the corresponding '*-data' fields,
+so that the entry does not break when these files move or the entry is copied to
+another machine.
+
+Relative paths are resolved against the base directory, which defaults to the
+directory of the active kubectl config file (where the entry is switched to).`
+
+// newFlattenCmd generates a new flatten command.
+func newFlattenCmd(global *rootOpts) *cobra.Command {
+	o := &flattenOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "flatten [config name]",
+		Short: "Embed referenced certificate and key files into the entry",
+		Long:  flattenLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.name = args[0]
+			return flattenRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.baseDir, "base-dir", "", "directory relative paths are resolved against, instead of the directory of the active kubectl config file")
+
+	return cmd
+}
+
+type flattenOpts struct {
+	name    string
+	baseDir string
+}
+
+func flattenRun(ctx context.Context, g *rootOpts, o *flattenOpts) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	entry, err := regs.Lookup(o.name)
+	if err != nil {
+		return err
+	}
+	if err := regs.CheckWritable(entry); err != nil {
+		return lockHint(err)
+	}
+
+	baseDir := o.baseDir
+	if baseDir == "" {
+		kcCfgPath, err := g.kubectlConfigPath()
+		if err != nil {
+			return err
+		}
+		baseDir = filepath.Dir(kcCfgPath)
+	}
+
+	cfg, err := kubecfg.ReadFile(entry.Path)
+	if err != nil {
+		return err
+	}
+	n, err := flattenConfig(cfg, baseDir)
+	if err != nil {
+		return err
+	}
+	name := regs.DisplayName(entry)
+	if n == 0 {
+		fmt.Printf("Entry %q references no files.\n", name)
+		return nil
+	}
+
+	b, err := cfg.Encode()
+	if err != nil {
+		return err
+	}
+	hook := hookEnv{to: entry.QualifiedName(), toPath: entry.Path}
+	if err := g.runHook(ctx, hookPreFlatten, hook); err != nil {
+		return err
+	}
+	if err := regs.Write(entry, bytes.NewReader(b), true); err != nil {
+		return lockHint(err)
+	}
+	g.runPostHook(ctx, hookPostFlatten, hook)
+	fmt.Printf("Embedded %d file(s) into entry %q.\n", n, name)
+	return nil
+}
+
+// flattenConfig inlines files referenced by the given kubectl config. Missing files are reported as an error.
+func flattenConfig(cfg *kubecfg.Config, baseDir string) (int, error) {
+	n, missing := cfg.Flatten(baseDir)
+	if len(missing) > 0 {
+		lines := make([]string, 0, len(missing))
+		for _, m := range missing {
+			lines = append(lines, "  "+m.String())
+		}
+		return 0, fmt.Errorf("cannot embed referenced files:\n%s", strings.Join(lines, "\n"))
+	}
+	return n, nil
+}
+
+// flattenContent returns the content of a kubectl config with referenced files inlined (see flattenConfig).
+func flattenContent(content io.Reader, baseDir string) (io.Reader, error) {
+	cfg, err := kubecfg.Read(content)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := flattenConfig(cfg, baseDir); err != nil {
+		return nil, err
+	}
+	b, err := cfg.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
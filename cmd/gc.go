@@ -0,0 +1,226 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/kubecfg"
+	"github.com/daishe/kubeconfig/registry"
+)
+
+const gcLong = `Removes expired entries (added or saved with the '--ttl' or '--expires' flag)
+from the registry. Removed entries are moved to the trash.
+
+Additionally, entries with expired client certificates and entries, whose
+clusters do not respond, can be removed on request.`
+
+// newGcCmd generates a new gc command.
+func newGcCmd(global *rootOpts) *cobra.Command {
+	o := &gcOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove expired entries from the registry",
+		Long:  gcLong,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return gcRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.expiredCerts, "expired-certs", false, "remove also entries with expired client certificates")
+	cmd.Flags().BoolVar(&o.unreachable, "unreachable", false, "remove also entries, whose clusters do not respond")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", 5*time.Second, "time to wait for a cluster to respond")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "only show entries that would be removed")
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "consider only entries matching the label selector (e.g. 'env=dev')")
+
+	return cmd
+}
+
+type gcOpts struct {
+	expiredCerts bool
+	unreachable  bool
+	timeout      time.Duration
+	dryRun       bool
+	selector     string
+}
+
+func gcRun(ctx context.Context, g *rootOpts, o *gcOpts) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	sel, err := registry.ParseSelector(o.selector)
+	if err != nil {
+		return err
+	}
+	ls, err := regs.List(sel)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	removed := 0
+	for _, e := range ls {
+		reason, err := gcReason(ctx, regs, e, o, now)
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			continue
+		}
+		name := regs.DisplayName(e)
+		if err := regs.CheckWritable(e); err != nil {
+			fmt.Printf("Skipping %q (%s): %v\n", name, reason, err)
+			continue
+		}
+		if o.dryRun {
+			fmt.Printf("Would remove %q (%s).\n", name, reason)
+			continue
+		}
+		hook := hookEnv{from: e.QualifiedName(), fromPath: e.Path}
+		if err := g.runHook(ctx, hookPreRemove, hook); err != nil {
+			return err
+		}
+		if err := regs.Remove(e); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %q (%s).\n", name, reason)
+		g.runPostHook(ctx, hookPostRemove, hook)
+		removed++
+	}
+	if !o.dryRun && removed == 0 {
+		fmt.Println("Nothing to remove.")
+	}
+	return nil
+}
+
+// gcReason returns the reason to remove the given entry (or an empty string, when the entry should be kept).
+func gcReason(ctx context.Context, regs *registry.Registries, e registry.Entry, o *gcOpts, now time.Time) (string, error) {
+	meta, err := regs.Metadata(e)
+	if err != nil {
+		return "", err
+	}
+	if meta.Expired(now) {
+		return "expired " + ui.HumanDuration(now.Sub(*meta.Expires)) + " ago", nil
+	}
+	if !o.expiredCerts && !o.unreachable {
+		return "", nil
+	}
+
+	cfg, err := kubecfg.ReadFile(e.Path)
+	if err != nil {
+		return "", nil // not a valid kubectl config, so nothing can be checked
+	}
+	if o.expiredCerts {
+		if t, ok := cfg.Expiry(); ok && !now.Before(t) {
+			return "client certificate expired " + ui.HumanDuration(now.Sub(t)) + " ago", nil
+		}
+	}
+	if o.unreachable && len(cfg.Clusters) > 0 && !anyClusterReachable(ctx, cfg, o.timeout) {
+		return "clusters do not respond", nil
+	}
+	return "", nil
+}
+
+// anyClusterReachable reports whether a connection can be established with at least one of clusters of the given
+// kubectl config.
+func anyClusterReachable(ctx context.Context, cfg *kubecfg.Config, timeout time.Duration) bool {
+	for _, c := range cfg.Clusters {
+		addr, err := serverAddress(c.Cluster.Server)
+		if err != nil {
+			return true // cannot tell, so keep it
+		}
+		d := net.Dialer{Timeout: timeout}
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			conn.Close()
+			return true
+		}
+	}
+	return false
+}
+
+// serverAddress returns the 'host:port' address of the given cluster server URL.
+func serverAddress(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid server URL %q", server)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// expiryOpts describes flags setting expiry time of written entries.
+type expiryOpts struct {
+	ttl     time.Duration
+	expires string
+}
+
+func (o *expiryOpts) addFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&o.ttl, "ttl", 0, "time after which the entry expires and is removed by 'kubeconfig gc' (e.g. 8h)")
+	fs.StringVar(&o.expires, "expires", "", "date, when the entry expires and is removed by 'kubeconfig gc' (e.g. 2006-01-02 or 2006-01-02T15:04:05Z07:00)")
+}
+
+// expiry returns the expiry time set by flags (or nil, when flags are not set).
+func (o *expiryOpts) expiry(now time.Time) (*time.Time, error) {
+	switch {
+	case o.ttl != 0 && o.expires != "":
+		return nil, fmt.Errorf("flags '--ttl' and '--expires' are mutually exclusive")
+	case o.ttl < 0:
+		return nil, fmt.Errorf("invalid ttl %s, it must be positive", o.ttl)
+	case o.ttl > 0:
+		t := now.Add(o.ttl).UTC().Truncate(time.Second)
+		return &t, nil
+	case o.expires != "":
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+			if t, err := time.ParseInLocation(layout, o.expires, time.Local); err == nil {
+				t = t.UTC()
+				return &t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid expiry date %q", o.expires)
+	}
+	return nil, nil
+}
+
+// setExpiry sets expiry time of the given entry (nil keeps the current one).
+func setExpiry(regs *registry.Registries, e registry.Entry, t *time.Time) error {
+	if t == nil {
+		return nil
+	}
+	meta, err := regs.Metadata(e)
+	if err != nil {
+		return err
+	}
+	updated := *meta
+	updated.Expires = t
+	return regs.SetMetadata(e, &updated)
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/daishe/kubeconfig/settings"
+)
+
+// Hooks executed by commands. Pre-hooks can abort the operation by exiting with a non-zero code, failures of post-hooks
+// are only reported.
+const (
+	hookPreSwitch  = "pre-switch"
+	hookPostSwitch = "post-switch"
+	hookPreSave    = "pre-save"
+	hookPostSave   = "post-save"
+	hookPreAdd     = "pre-add"
+	hookPostAdd    = "post-add"
+	hookPreImport  = "pre-import"
+	hookPostImport = "post-import"
+
+	hookPreEdit       = "pre-edit"
+	hookPostEdit      = "post-edit"
+	hookPreRewrite    = "pre-rewrite"
+	hookPostRewrite   = "post-rewrite"
+	hookPreRemove     = "pre-remove"
+	hookPostRemove    = "post-remove"
+	hookPreRename     = "pre-rename"
+	hookPostRename    = "post-rename"
+	hookPreDuplicate  = "pre-duplicate"
+	hookPostDuplicate = "post-duplicate"
+	hookPreRestore    = "pre-restore"
+	hookPostRestore   = "post-restore"
+	hookPreExtract    = "pre-extract"
+	hookPostExtract   = "post-extract"
+	hookPreFlatten    = "pre-flatten"
+	hookPostFlatten   = "post-flatten"
+)
+
+// Environment variables describing the operation to hooks.
+const (
+	envHook              = "KUBECONFIG_HOOK"
+	envHookFrom          = "KUBECONFIG_HOOK_FROM"
+	envHookFromPath      = "KUBECONFIG_HOOK_FROM_PATH"
+	envHookTo            = "KUBECONFIG_HOOK_TO"
+	envHookToPath        = "KUBECONFIG_HOOK_TO_PATH"
+	envHookKubectlConfig = "KUBECONFIG_HOOK_KUBECTL_CONFIG"
+)
+
+// hookEnv describes the operation a hook is executed for. Names are qualified entry names (empty, when the source or
+// target is not a registry entry).
+type hookEnv struct {
+	from     string
+	fromPath string
+	to       string
+	toPath   string
+}
+
+// hookPath returns the path to the executable of the hook with the given name, or an empty string if there is none.
+// Files without the executable bit are ignored (as git does).
+func hookPath(dir string, name string) (string, error) {
+	candidates := []string{filepath.Join(dir, name)}
+	if runtime.GOOS == "windows" {
+		matches, err := filepath.Glob(filepath.Join(dir, name+".*"))
+		if err != nil {
+			return "", err
+		}
+		candidates = append(candidates, matches...)
+	}
+	for _, path := range candidates {
+		stat, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot stat hook %q: %w", path, err)
+		}
+		if stat.IsDir() || (runtime.GOOS != "windows" && stat.Mode()&0111 == 0) {
+			continue
+		}
+		return path, nil
+	}
+	return "", nil
+}
+
+// runHook executes the hook with the given name (if present in the hooks directory and hooks are not disabled with the
+// '--no-hooks' flag). Output of the hook is passed to stderr, so that it does not mix with structured output.
+func (o *rootOpts) runHook(ctx context.Context, name string, env hookEnv) error {
+	if o.noHooks {
+		return nil
+	}
+	cfg, err := o.settings()
+	if err != nil {
+		return err
+	}
+	dir, err := settings.HooksDir()
+	if err != nil {
+		return err
+	}
+	path, err := hookPath(dir, name)
+	if err != nil || path == "" {
+		return err
+	}
+	kcCfgPath, err := o.kubectlConfigPath()
+	if err != nil {
+		return err
+	}
+
+	timeout := cfg.HookTimeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c := exec.CommandContext(ctx, path)
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	c.WaitDelay = time.Second
+	c.Env = append(os.Environ(),
+		envHook+"="+name,
+		envHookFrom+"="+env.from,
+		envHookFromPath+"="+env.fromPath,
+		envHookTo+"="+env.to,
+		envHookToPath+"="+env.toPath,
+		envHookKubectlConfig+"="+kcCfgPath,
+	)
+	if err := c.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%s hook timed out after %s (see 'hooks.timeout' setting)", name, timeout)
+		}
+		return fmt.Errorf("%s hook failed: %w; To skip hooks use '--no-hooks' flag", name, err)
+	}
+	return nil
+}
+
+// preHook returns the function executing the hook with the given name, passed to writeEntry (or copyEntry) to be run
+// after the overwrite is confirmed.
+func (o *rootOpts) preHook(ctx context.Context, name string, env hookEnv) func() error {
+	return func() error {
+		return o.runHook(ctx, name, env)
+	}
+}
+
+// runPostHook executes the hook with the given name after the operation is done, so its failure is only reported.
+func (o *rootOpts) runPostHook(ctx context.Context, name string, env hookEnv) {
+	if err := o.runHook(ctx, name, env); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/registry"
+)
+
+const importLong = `Imports the given kubectl config file into the kubeconfig registry under the
+provided name (by default, the file name without extension). Use '-' to read the
+config from the standard input.`
+
+// newImportCmd generates a new import command.
+func newImportCmd(global *rootOpts) *cobra.Command {
+	o := &importOpts{}
+
+	cmd := &cobra.Command{
+		Use:     "import [file] [name in registry]",
+		Short:   "Import a kubectl config file",
+		Long:    importLong,
+		Aliases: []string{"imp"},
+		Args:    cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.file = args[0]
+			if len(args) > 1 {
+				o.name = args[1]
+			}
+			return importRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&o.force, "force", "f", false, "force override, if the entry with the provided name already exists in the registry")
+	cmd.Flags().BoolVar(&o.flatten, "flatten", false, "embed referenced certificate and key files into the entry")
+	o.expiryOpts.addFlags(cmd.Flags())
+
+	return cmd
+}
+
+type importOpts struct {
+	expiryOpts
+	file    string
+	name    string
+	force   bool
+	flatten bool
+}
+
+func importRun(ctx context.Context, g *rootOpts, o *importOpts) error {
+	expires, err := o.expiry(time.Now())
+	if err != nil {
+		return err
+	}
+
+	name := o.name
+	if name == "" {
+		if o.file == "-" {
+			return fmt.Errorf("name of the entry is required, when importing from the standard input")
+		}
+		name = strings.TrimSuffix(filepath.Base(o.file), filepath.Ext(o.file))
+	}
+
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	entry, err := regs.Target(name)
+	if err != nil {
+		return err
+	}
+
+	hook := hookEnv{to: entry.QualifiedName(), toPath: entry.Path}
+	if o.file != "-" {
+		hook.fromPath = o.file
+	}
+
+	var content io.Reader = os.Stdin
+	baseDir := "."
+	if o.file != "-" {
+		f, err := registry.Read(o.file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		content = f
+		baseDir = filepath.Dir(o.file)
+	}
+	if o.flatten {
+		if content, err = flattenContent(content, baseDir); err != nil {
+			return err
+		}
+	}
+
+	if err := writeEntry(g, regs, entry, content, o.force, g.preHook(ctx, hookPreImport, hook)); err != nil {
+		return err
+	}
+	if err := setExpiry(regs, entry, expires); err != nil {
+		return err
+	}
+
+	fmt.Printf("File %q imported to the registry as %q.\n", o.file, regs.DisplayName(entry))
+	g.runPostHook(ctx, hookPostImport, hook)
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/registry"
+)
+
+const labelLong = `Updates labels of the requested entry in the kubeconfig registry.
+
+Labels are given in the 'key=value' form. A label can be removed by giving its
+key followed by a dash (e.g. 'env-'). When no labels are given, the command shows
+the current labels of the entry.
+
+Labels can be then used to filter entries with selectors, e.g.
+
+  kubeconfig list -l env=prod,team!=infra`
+
+// newLabelCmd generates a new label command
+func newLabelCmd(global *rootOpts) *cobra.Command {
+	o := &labelOpts{}
+
+	cmd := &cobra.Command{
+		Use:     "label [config name] [key=value | key-]...",
+		Short:   "Update labels of a kubectl config file in the registry",
+		Long:    labelLong,
+		Aliases: []string{"labels", "lab"},
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.name = args[0]
+			o.labels = args[1:]
+			return labelRun(global, o)
+		},
+	}
+
+	return cmd
+}
+
+type labelOpts struct {
+	name   string
+	labels []string
+}
+
+func labelRun(g *rootOpts, o *labelOpts) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+
+	entry, err := regs.Lookup(o.name)
+	if err != nil {
+		return err
+	}
+
+	meta, err := regs.Metadata(entry)
+	if err != nil {
+		return err
+	}
+
+	if len(o.labels) == 0 {
+		for _, l := range registry.SortedLabels(meta.Labels) {
+			fmt.Println(l)
+		}
+		return nil
+	}
+
+	labels := map[string]string{}
+	for k, v := range meta.Labels {
+		labels[k] = v
+	}
+	for _, l := range o.labels {
+		if k := strings.TrimSuffix(l, "-"); k != l && !strings.Contains(l, "=") {
+			if err := registry.ValidateLabel(k, ""); err != nil {
+				return err
+			}
+			delete(labels, k)
+			continue
+		}
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid label %q, expected 'key=value' or 'key-'", l)
+		}
+		if err := registry.ValidateLabel(kv[0], kv[1]); err != nil {
+			return err
+		}
+		labels[kv[0]] = kv[1]
+	}
+
+	meta.Labels = labels
+	if err := regs.SetMetadata(entry, meta); err != nil {
+		return lockHint(err)
+	}
+
+	fmt.Printf("Labels of entry %q updated.\n", regs.DisplayName(entry))
+	return nil
+}
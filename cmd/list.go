@@ -16,42 +16,176 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/daishe/kubeconfig/cmd/ui"
 	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
+	"github.com/daishe/kubeconfig/state"
 )
 
 // newListCmd generates a new list command
 func newListCmd(global *rootOpts) *cobra.Command {
+	o := &listOpts{}
+
 	cmd := &cobra.Command{
 		Use:     "list",
 		Short:   "Show all kubectl config files",
-		Long:    `Shows names of all kubectl config files in the kubeconfig registry.`,
+		Long:    `Shows names of all kubectl config files in the kubeconfig registry (together with registry names, when multiple registries are configured).`,
 		Aliases: []string{"lst", "ls", "l", "li"},
 		Run: func(cmd *cobra.Command, args []string) {
-			listRun(global)
+			listRun(global, o)
 		},
 	}
 
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format (text, json or yaml), instead of the one set in settings")
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "show only entries matching the label selector (e.g. 'env=prod,team!=infra')")
+	cmd.Flags().BoolVar(&o.showLabels, "show-labels", false, "show labels of entries")
+	cmd.Flags().BoolVar(&o.tree, "tree", false, "show entries as a hierarchy of folders (with numbers of entries in each folder)")
+	cmd.Flags().StringVar(&o.sort, "sort", sortByName, "sort order of entries (name, recent, frequent or favourite)")
+
 	return cmd
 }
 
-func listRun(g *rootOpts) {
-	reg, err := g.regPath()
+type listOpts struct {
+	output     string
+	selector   string
+	showLabels bool
+	tree       bool
+	sort       string
+}
+
+type listItem struct {
+	Name     string            `json:"name" yaml:"name"`
+	Registry string            `json:"registry" yaml:"registry"`
+	Current  bool              `json:"current" yaml:"current"`
+	Labels   map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Pinned   bool              `json:"pinned" yaml:"pinned"`
+	LastUsed *time.Time        `json:"lastUsed,omitempty" yaml:"lastUsed,omitempty"`
+	Count    int               `json:"count" yaml:"count"`
+	Expires  *time.Time        `json:"expires,omitempty" yaml:"expires,omitempty"`
+}
+
+// listItems describes the given entries (with their display names) in the structured form.
+func listItems(regs *registry.Registries, ls []registry.Entry, cmp []bool, names []string, st *state.State) ([]listItem, error) {
+	items := make([]listItem, 0, len(names))
+	for i := range names {
+		meta, err := regs.Metadata(ls[i])
+		if err != nil {
+			return nil, err
+		}
+		usage := st.UsageOf(ls[i].QualifiedName())
+		item := listItem{Name: names[i], Registry: ls[i].Source.Name, Current: cmp[i], Labels: meta.Labels, Pinned: st.PinIndex(ls[i].QualifiedName()) >= 0, Count: usage.Count, Expires: meta.Expires}
+		if !usage.LastUsed.IsZero() {
+			item.LastUsed = &usage.LastUsed
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func listRun(g *rootOpts, o *listOpts) {
+	out, err := g.output(o.output)
 	ui.DisplayAndExitOnError(err)
 
-	kcCfgPath, err := registry.KubectlConfigPath()
+	regs, err := g.registries()
+	ui.DisplayAndExitOnError(err)
+
+	kcCfgPath, err := g.kubectlConfigPath()
 	ui.DisplayAndExitOnError(err)
 
 	kcCfgHash, err := registry.Hash(kcCfgPath)
 	ui.DisplayAndExitOnError(err)
 
-	ls, cmp, err := registry.ListWithCmp(reg, kcCfgHash)
+	sel, err := registry.ParseSelector(o.selector)
+	ui.DisplayAndExitOnError(err)
+
+	ls, cmp, err := regs.ListWithCmp(kcCfgHash, sel)
+	ui.DisplayAndExitOnError(err)
+
+	st, err := state.Load()
+	ui.DisplayAndExitOnError(err)
+
+	err = sortEntries(ls, cmp, o.sort, st)
 	ui.DisplayAndExitOnError(err)
 
-	for _, name := range ui.AnnotateNamesWithCurrent(ui.ListToNames(reg, ls), cmp) {
+	names := registry.DisplayNames(ls)
+
+	if out != settings.OutputText {
+		items, err := listItems(regs, ls, cmp, names, st)
+		ui.DisplayAndExitOnError(err)
+		ui.DisplayAndExitOnError(ui.PrintStructured(out, items))
+		return
+	}
+
+	theme, err := g.theme()
+	ui.DisplayAndExitOnError(err)
+
+	if o.tree {
+		for _, line := range listTree(regs, ls, cmp, names, o.showLabels, theme) {
+			fmt.Println(line)
+		}
+		return
+	}
+
+	if regs.Multiple() {
+		names = ui.AnnotateNamesWithRegistry(names, ls)
+	}
+	if o.showLabels {
+		labels := make([][]string, 0, len(ls))
+		for _, e := range ls {
+			meta, err := regs.Metadata(e)
+			ui.DisplayAndExitOnError(err)
+			labels = append(labels, registry.SortedLabels(meta.Labels))
+		}
+		names = ui.AnnotateNamesWithLabels(names, labels)
+	}
+	now := time.Now()
+	for i, e := range ls {
+		meta, err := regs.Metadata(e)
+		ui.DisplayAndExitOnError(err)
+		names[i] += expiryAnnotation(meta, now)
+	}
+	for _, name := range ui.AnnotateNamesWithCurrentColored(names, cmp, theme.Current) {
 		fmt.Println(name)
 	}
 }
+
+// listTree renders entries as a hierarchy of folders. Annotations are not aligned, as names are indented.
+func listTree(regs *registry.Registries, ls []registry.Entry, cmp []bool, names []string, showLabels bool, theme ui.Theme) []string {
+	annotations := make([]string, len(ls))
+	for i, e := range ls {
+		if regs.Multiple() {
+			annotations[i] += fmt.Sprintf("   [%s]", e.Source.Name)
+		}
+		if showLabels {
+			meta, err := regs.Metadata(e)
+			ui.DisplayAndExitOnError(err)
+			if labels := registry.SortedLabels(meta.Labels); len(labels) > 0 {
+				annotations[i] += "   " + strings.Join(labels, ",")
+			}
+		}
+		meta, err := regs.Metadata(e)
+		ui.DisplayAndExitOnError(err)
+		annotations[i] += expiryAnnotation(meta, time.Now())
+		if cmp[i] {
+			annotations[i] += ui.Colorize(theme.Current, "   <---- current -----")
+		}
+	}
+	return ui.Tree(names, annotations)
+}
+
+// expiryAnnotation returns the annotation with the remaining time of the entry (or an empty string, if the entry does
+// not expire).
+func expiryAnnotation(meta *registry.EntryMetadata, now time.Time) string {
+	switch {
+	case meta.Expires == nil:
+		return ""
+	case meta.Expired(now):
+		return ui.Colorize("red", "   (expired)")
+	}
+	return fmt.Sprintf("   (expires in %s)", ui.HumanDuration(meta.Expires.Sub(now)))
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/registry"
+)
+
+const lockLong = `Locks the requested entry in the kubeconfig registry, so that no command
+(including add, save and edit) modifies it, until it is unlocked.
+
+With the '--entire-registry' flag, the argument names the registry (defaulting to
+the write registry) and all its entries are locked.`
+
+// newLockCmd generates a new lock command
+func newLockCmd(global *rootOpts) *cobra.Command {
+	o := &lockOpts{lock: true}
+
+	cmd := &cobra.Command{
+		Use:   "lock [config name]",
+		Short: "Lock a kubectl config file in the registry against modifications",
+		Long:  lockLong,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				o.name = args[0]
+			}
+			return lockRun(global, o)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&o.entireRegistry, "entire-registry", "R", false, "lock the entire registry instead of a single entry")
+
+	return cmd
+}
+
+// newUnlockCmd generates a new unlock command
+func newUnlockCmd(global *rootOpts) *cobra.Command {
+	o := &lockOpts{lock: false}
+
+	cmd := &cobra.Command{
+		Use:   "unlock [config name]",
+		Short: "Unlock a locked kubectl config file in the registry",
+		Long:  `Unlocks the requested entry (or with the '--entire-registry' flag, the requested registry) previously locked with the lock command.`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				o.name = args[0]
+			}
+			return lockRun(global, o)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&o.entireRegistry, "entire-registry", "R", false, "unlock the entire registry instead of a single entry")
+
+	return cmd
+}
+
+type lockOpts struct {
+	name           string
+	entireRegistry bool
+	lock           bool
+}
+
+func lockRun(g *rootOpts, o *lockOpts) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+
+	state := "unlocked"
+	if o.lock {
+		state = "locked"
+	}
+
+	if o.entireRegistry {
+		var src *registry.Source
+		if o.name == "" {
+			if src, err = regs.Writable(); err != nil {
+				return err
+			}
+		} else {
+			s, ok := regs.Source(o.name)
+			if !ok {
+				return fmt.Errorf("registry %q is not configured", o.name)
+			}
+			src = s
+		}
+		if err := regs.SetLocked(src, o.lock); err != nil {
+			return err
+		}
+		fmt.Printf("Registry %q %s.\n", src.Name, state)
+		return nil
+	}
+
+	if o.name == "" {
+		return fmt.Errorf("entry name not provided")
+	}
+	entry, err := regs.Lookup(o.name)
+	if err != nil {
+		return err
+	}
+	if err := regs.SetEntryLocked(entry, o.lock); err != nil {
+		return err
+	}
+	fmt.Printf("Entry %q %s.\n", regs.DisplayName(entry), state)
+	return nil
+}
+
+// lockHint extends errors caused by locked entries with a hint how to unlock them.
+func lockHint(err error) error {
+	if err != nil && errors.Is(err, registry.ErrLocked) {
+		return fmt.Errorf("%w; To modify it, unlock it first with 'kubeconfig unlock' command", err)
+	}
+	return err
+}
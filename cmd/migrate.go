@@ -0,0 +1,189 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
+)
+
+const migrateLong = `Upgrades the layout of registries (all, or the given ones) in place to the
+format supported by this version of kubeconfig. Registries without a manifest
+(the '.registry.yaml' file) are legacy plain directories of kubectl config
+files.
+
+Every registry is backed up (as an archive in the backup directory) before it
+is migrated. Registries using a format newer than the supported one are never
+modified - upgrade kubeconfig instead. Read-only and locked registries are
+skipped.
+
+Pending steps are shown and have to be confirmed (or forced with '--yes').`
+
+// newMigrateCmd generates a new migrate command.
+func newMigrateCmd(global *rootOpts) *cobra.Command {
+	o := &migrateOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "migrate [registry names...]",
+		Short: "Upgrade the format of registries",
+		Long:  migrateLong,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.names = args
+			return migrateRun(global, o)
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "only show pending migration steps")
+	cmd.Flags().BoolVarP(&o.yes, "yes", "y", false, "do not ask for confirmation")
+
+	return cmd
+}
+
+type migrateOpts struct {
+	names  []string
+	dryRun bool
+	yes    bool
+}
+
+func migrateRun(g *rootOpts, o *migrateOpts) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	sources := regs.Sources()
+	if len(o.names) > 0 {
+		sources = nil
+		for _, name := range o.names {
+			s, ok := regs.Source(name)
+			if !ok {
+				return fmt.Errorf("registry %q is not configured", name)
+			}
+			sources = append(sources, s)
+		}
+	}
+
+	var pending []*registry.Source
+	for _, s := range sources {
+		ok, err := migratePlan(regs, s)
+		if err != nil {
+			return err
+		}
+		if ok {
+			pending = append(pending, s)
+		}
+	}
+
+	if len(pending) == 0 || o.dryRun {
+		return nil
+	}
+	if !o.yes {
+		if !ui.Interactive() {
+			return fmt.Errorf("migrating registries requires confirmation; If that is intended confirm with '--yes' flag")
+		}
+		ok, err := ui.Confirm(fmt.Sprintf("Migrate %d registries", len(pending)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	for _, s := range pending {
+		path, err := backupRegistry(g, s)
+		if err != nil {
+			return err
+		}
+		if err := registry.Migrate(s); err != nil {
+			return fmt.Errorf("%w; The registry can be restored from backup %q", err, path)
+		}
+		fmt.Printf("Migrated registry %q to format version %d (backup: %s).\n", s.Name, registry.FormatVersion, path)
+	}
+	return nil
+}
+
+// migratePlan prints pending migration steps of the given registry. It reports whether the registry should be migrated.
+func migratePlan(regs *registry.Registries, s *registry.Source) (bool, error) {
+	if _, err := os.Stat(s.Path); errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("Registry %q does not exist yet, nothing to migrate.\n", s.Name)
+		return false, nil
+	}
+	m, err := registry.ReadRegistryManifest(s.Path)
+	if err != nil {
+		return false, err
+	}
+	steps, err := registry.PendingMigrations(s)
+	if err != nil {
+		return false, err
+	}
+	if len(steps) == 0 {
+		fmt.Printf("Registry %q is up to date (format version %d).\n", s.Name, m.Format)
+		return false, nil
+	}
+
+	layout := fmt.Sprintf("format version %d", m.Format)
+	if m.Format == 0 {
+		layout = "legacy plain-directory registry"
+	}
+	if s.ReadOnly {
+		fmt.Printf("Skipping registry %q (%s): it is read-only.\n", s.Name, layout)
+		return false, nil
+	}
+	locked, err := regs.Locked(s)
+	if err != nil {
+		return false, err
+	}
+	if locked {
+		fmt.Printf("Skipping registry %q (%s): it is locked; To migrate it use 'kubeconfig unlock --entire-registry %s' first.\n", s.Name, layout, s.Name)
+		return false, nil
+	}
+
+	fmt.Printf("Registry %q (%s) needs migrating to format version %d:\n", s.Name, layout, registry.FormatVersion)
+	for _, step := range steps {
+		fmt.Printf("  %d -> %d: %s\n", step.From, step.To, step.Description)
+	}
+	return true, nil
+}
+
+// backupRegistry stores an archive of the entire registry in the backup directory and returns its path. The archive is
+// stored even when backups are disabled in settings.
+func backupRegistry(g *rootOpts, s *registry.Source) (string, error) {
+	cfg, err := g.settings()
+	if err != nil {
+		return "", err
+	}
+	dir, err := settings.BackupDir()
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := registry.ArchiveRegistry(buf, s.Path); err != nil {
+		return "", err
+	}
+	keep := cfg.BackupKeep()
+	if keep < 1 {
+		keep = 1
+	}
+	return registry.Backup(dir, "registries/"+s.Name, buf, keep)
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/state"
+)
+
+// newPinCmd generates a new pin command
+func newPinCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "pin [config name]",
+		Short:   "Add a kubectl config file to favourites",
+		Long:    `Adds the requested entry to favourites, which are presented first in interactive prompts.`,
+		Aliases: []string{"fav", "favourite"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pinRun(global, args[0], true)
+		},
+	}
+
+	return cmd
+}
+
+// newUnpinCmd generates a new unpin command
+func newUnpinCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "unpin [config name]",
+		Short:   "Remove a kubectl config file from favourites",
+		Long:    `Removes the requested entry from favourites.`,
+		Aliases: []string{"unfav", "unfavourite"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pinRun(global, args[0], false)
+		},
+	}
+
+	return cmd
+}
+
+func pinRun(g *rootOpts, name string, pin bool) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+
+	entry, err := regs.Lookup(name)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	err = state.Update(func(s *state.State) error {
+		if pin {
+			changed = s.Pin(entry.QualifiedName())
+		} else {
+			changed = s.Unpin(entry.QualifiedName())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case pin && changed:
+		fmt.Printf("Entry %q added to favourites.\n", regs.DisplayName(entry))
+	case pin:
+		fmt.Printf("Entry %q is already in favourites.\n", regs.DisplayName(entry))
+	case changed:
+		fmt.Printf("Entry %q removed from favourites.\n", regs.DisplayName(entry))
+	default:
+		fmt.Printf("Entry %q is not in favourites.\n", regs.DisplayName(entry))
+	}
+	return nil
+}
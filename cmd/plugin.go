@@ -0,0 +1,344 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/settings"
+)
+
+// pluginPrefix is the prefix of names of executables exposed as kubeconfig subcommands.
+const pluginPrefix = "kubeconfig-"
+
+// pluginAnnotation marks subcommands provided by plugins (its value is the path to the plugin executable).
+const pluginAnnotation = "kubeconfig/plugin"
+
+// Environment variables describing the kubeconfig configuration to plugins.
+const (
+	envPluginRegistry      = "KUBECONFIG_PLUGIN_REGISTRY"
+	envPluginRegistries    = "KUBECONFIG_PLUGIN_REGISTRIES"
+	envPluginKubectlConfig = "KUBECONFIG_PLUGIN_KUBECTL_CONFIG"
+	envPluginExecutable    = "KUBECONFIG_PLUGIN_EXECUTABLE"
+)
+
+const pluginLong = `Plugins are executables named 'kubeconfig-<name>' found in directories listed in
+the ${PATH} environment variable. They are exposed as 'kubeconfig <name>'
+subcommands and receive all arguments unchanged.
+
+Plugins are executed with the following environment variables set:
+  KUBECONFIG_PLUGIN_REGISTRY        path to the registry new entries are written to
+  KUBECONFIG_PLUGIN_REGISTRIES      paths to all registries, in the search order
+  KUBECONFIG_PLUGIN_KUBECTL_CONFIG  path to the active kubectl config file
+  KUBECONFIG_PLUGIN_EXECUTABLE      path to the kubeconfig executable
+
+Global flags (e.g. '--registry') given before the plugin arguments are consumed
+by kubeconfig and reflected in these variables.
+
+When multiple plugins have the same name, the first one found in ${PATH} is used.
+Plugins cannot override built-in commands. ${PATH} is searched only when no
+built-in command matches, so plugin names are not offered by shell completion.`
+
+// plugin describes a single plugin executable.
+type plugin struct {
+	name string
+	path string
+}
+
+// discoverPlugins returns plugin executables found in directories listed in ${PATH}, in the search order (so plugins
+// found later are shadowed by the earlier ones with the same name).
+func discoverPlugins() []plugin {
+	var res []plugin
+	seenDirs := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" || seenDirs[dir] {
+			continue
+		}
+		seenDirs[dir] = true
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue // missing or unreadable directories are common in ${PATH}
+		}
+		for _, f := range files {
+			name, ok := pluginName(f.Name())
+			if !ok || f.IsDir() {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil || !isExecutable(info.Mode()) {
+				continue
+			}
+			res = append(res, plugin{name: name, path: filepath.Join(dir, f.Name())})
+		}
+	}
+	return res
+}
+
+// pluginName returns the name of the subcommand provided by the executable with the given file name.
+func pluginName(file string) (string, bool) {
+	if !strings.HasPrefix(file, pluginPrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(file, pluginPrefix)
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(name))
+		known := false
+		for _, e := range filepath.SplitList(os.Getenv("PATHEXT")) {
+			if ext != "" && strings.ToLower(e) == ext {
+				known = true
+			}
+		}
+		if !known {
+			return "", false
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name, name != "" && !strings.HasPrefix(name, "-")
+}
+
+func isExecutable(mode os.FileMode) bool {
+	if runtime.GOOS == "windows" {
+		return mode.IsRegular() || mode&os.ModeSymlink != 0
+	}
+	return mode&0111 != 0
+}
+
+// builtinCommand reports whether the given name (or alias) is taken by a built-in subcommand of the root command.
+func builtinCommand(root *cobra.Command, name string) bool {
+	if name == "help" {
+		return true
+	}
+	for _, c := range root.Commands() {
+		if _, ok := c.Annotations[pluginAnnotation]; ok {
+			continue
+		}
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// needsPlugins reports whether plugins have to be discovered for the given arguments - when they do not name a built-in
+// subcommand (so may name a plugin) or ask for the help listing all subcommands. Searching ${PATH} is skipped for
+// built-in subcommands and shell completion requests, that are run often (e.g. by shell hooks).
+func needsPlugins(root *cobra.Command, args []string) bool {
+	if len(args) > 0 && (args[0] == cobra.ShellCompRequestCmd || args[0] == cobra.ShellCompNoDescRequestCmd) {
+		return false
+	}
+	c, _, err := root.Find(args)
+	return err != nil || c == root
+}
+
+// addPluginCmds adds discovered plugins as subcommands of the root command.
+func addPluginCmds(root *cobra.Command, global *rootOpts) {
+	seen := map[string]bool{}
+	for _, p := range discoverPlugins() {
+		if seen[p.name] || builtinCommand(root, p.name) {
+			continue
+		}
+		seen[p.name] = true
+		root.AddCommand(newPluginRunCmd(global, p))
+	}
+}
+
+// newPluginRunCmd generates a new command running the given plugin.
+func newPluginRunCmd(global *rootOpts, p plugin) *cobra.Command {
+	return &cobra.Command{
+		Use:                p.name,
+		Short:              fmt.Sprintf("Plugin (%s)", p.path),
+		Annotations:        map[string]string{pluginAnnotation: p.path},
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pluginRun(cmd, global, p, args)
+		},
+	}
+}
+
+func pluginRun(cmd *cobra.Command, g *rootOpts, p plugin, args []string) error {
+	// flags are not parsed for plugins, so the global ones (given before the plugin name) need to be consumed here
+	args, err := parseGlobalFlags(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	env, err := pluginEnv(g)
+	if err != nil {
+		return err
+	}
+
+	c := exec.CommandContext(cmd.Context(), p.path, args...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	c.Env = append(os.Environ(), env...)
+	if err := c.Run(); err != nil {
+		exitErr := &exec.ExitError{}
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			os.Exit(exitErr.ExitCode()) // the plugin reported the problem itself
+		}
+		return fmt.Errorf("running plugin %q filed: %w", p.path, err)
+	}
+	return nil
+}
+
+// parseGlobalFlags consumes persistent flags of the root command (given before the plugin name) from the beginning of
+// arguments and returns the rest of them.
+func parseGlobalFlags(cmd *cobra.Command, args []string) ([]string, error) {
+	fs := cmd.Root().PersistentFlags()
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") && args[0] != "--" {
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(args[0], "--"), "=")
+		f := fs.Lookup(name)
+		if f == nil {
+			break
+		}
+		n := 1
+		if !hasValue && f.NoOptDefVal == "" && len(args) > 1 {
+			n = 2
+		}
+		if err := fs.Parse(args[:n]); err != nil {
+			return nil, err
+		}
+		args = args[n:]
+	}
+	return args, nil
+}
+
+// pluginEnv returns environment variables describing the kubeconfig configuration to plugins.
+func pluginEnv(g *rootOpts) ([]string, error) {
+	regs, err := g.registries()
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, s := range regs.Sources() {
+		paths = append(paths, s.Path)
+	}
+	write := ""
+	if s, err := regs.Writable(); err == nil {
+		write = s.Path
+	}
+	kcCfgPath, err := g.kubectlConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain path to the kubeconfig executable: %w", err)
+	}
+	return []string{
+		envPluginRegistry + "=" + write,
+		envPluginRegistries + "=" + strings.Join(paths, string(os.PathListSeparator)),
+		envPluginKubectlConfig + "=" + kcCfgPath,
+		envPluginExecutable + "=" + self,
+	}, nil
+}
+
+// newPluginCmd generates a new plugin command.
+func newPluginCmd(root *cobra.Command, global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "Inspect plugins providing additional subcommands",
+		Long:  pluginLong,
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newPluginListCmd(root, global))
+
+	return cmd
+}
+
+// newPluginListCmd generates a new plugin list command.
+func newPluginListCmd(root *cobra.Command, global *rootOpts) *cobra.Command {
+	o := &pluginListOpts{}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List discovered plugins",
+		Long:    "Lists plugins found in ${PATH} together with the ones that cannot be used, because of name conflicts.",
+		Aliases: []string{"ls", "l"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pluginListRun(root, global, o)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format (text, json or yaml), instead of the one set in settings")
+
+	return cmd
+}
+
+type pluginListOpts struct {
+	output string
+}
+
+type pluginItem struct {
+	Name       string `json:"name" yaml:"name"`
+	Path       string `json:"path" yaml:"path"`
+	Active     bool   `json:"active" yaml:"active"`
+	ShadowedBy string `json:"shadowedBy,omitempty" yaml:"shadowedBy,omitempty"`
+}
+
+func pluginListRun(root *cobra.Command, g *rootOpts, o *pluginListOpts) error {
+	out, err := g.output(o.output)
+	if err != nil {
+		return err
+	}
+
+	items := []pluginItem{}
+	first := map[string]string{}
+	for _, p := range discoverPlugins() {
+		item := pluginItem{Name: p.name, Path: p.path}
+		switch {
+		case builtinCommand(root, p.name):
+			item.ShadowedBy = "built-in command"
+		case first[p.name] != "":
+			item.ShadowedBy = first[p.name]
+		default:
+			item.Active = true
+			first[p.name] = p.path
+		}
+		items = append(items, item)
+	}
+
+	if out != settings.OutputText {
+		return ui.PrintStructured(out, items)
+	}
+
+	if len(items) == 0 {
+		fmt.Printf("No plugins (executables named '%s<name>') found in ${PATH}.\n", pluginPrefix)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPATH\tSTATUS")
+	for _, item := range items {
+		status := "active"
+		if !item.Active {
+			status = ui.Colorize("yellow", "shadowed by "+item.ShadowedBy)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", item.Name, item.Path, status)
+	}
+	return w.Flush()
+}
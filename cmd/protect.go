@@ -0,0 +1,276 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/registry"
+)
+
+const protectLong = `Marks the requested entry in the kubeconfig registry as protected.
+
+Switching to a protected entry requires typing its name to confirm (or the
+'--yes' flag in non-interactive use). Entries can be also protected by naming
+rules and labels, with the 'protection.patterns' and 'protection.selector'
+settings. Additionally, with the 'protection.safe-entry' and
+'protection.revert-after' settings, kubeconfig automatically switches back to a
+safe entry after the given time.`
+
+// newProtectCmd generates a new protect command
+func newProtectCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "protect [config name]",
+		Short: "Mark a kubectl config file in the registry as protected",
+		Long:  protectLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return protectRun(global, args[0], true)
+		},
+	}
+
+	return cmd
+}
+
+// newUnprotectCmd generates a new unprotect command
+func newUnprotectCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unprotect [config name]",
+		Short: "Remove the protected mark from a kubectl config file in the registry",
+		Long:  `Removes the protected mark from the requested entry in the kubeconfig registry. Entries protected by naming rules or labels stay protected.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return protectRun(global, args[0], false)
+		},
+	}
+
+	return cmd
+}
+
+func protectRun(g *rootOpts, name string, protect bool) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+
+	entry, err := regs.Lookup(name)
+	if err != nil {
+		return err
+	}
+
+	meta, err := regs.Metadata(entry)
+	if err != nil {
+		return err
+	}
+	meta.Protected = protect
+	if err := regs.SetMetadata(entry, meta); err != nil {
+		return lockHint(err)
+	}
+
+	if protect {
+		fmt.Printf("Entry %q is now protected.\n", regs.DisplayName(entry))
+		return nil
+	}
+	if ok, err := isProtected(g, regs, entry); err != nil {
+		return err
+	} else if ok {
+		fmt.Printf("Entry %q is no longer explicitly protected, but it is still protected by settings.\n", regs.DisplayName(entry))
+		return nil
+	}
+	fmt.Printf("Entry %q is no longer protected.\n", regs.DisplayName(entry))
+	return nil
+}
+
+// isProtected reports whether the given entry is protected, either explicitly (by metadata) or by protection settings.
+func isProtected(g *rootOpts, regs *registry.Registries, entry registry.Entry) (bool, error) {
+	meta, err := regs.Metadata(entry)
+	if err != nil {
+		return false, err
+	}
+	if meta.Protected {
+		return true, nil
+	}
+
+	cfg, err := g.settings()
+	if err != nil {
+		return false, err
+	}
+	for _, p := range cfg.ProtectionPatterns() {
+		if registry.MatchGlob(p, entry.Name) || registry.MatchGlob(p, entry.QualifiedName()) {
+			return true, nil
+		}
+	}
+	if cfg.Protection.Selector != "" {
+		sel, err := registry.ParseSelector(cfg.Protection.Selector)
+		if err != nil {
+			return false, fmt.Errorf("setting %q: %w", "protection.selector", err)
+		}
+		if sel.Matches(meta.Labels) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// protectedYesUsage is the usage of the '--yes' flag of commands guarded by guardProtected.
+const protectedYesUsage = "confirm using a protected entry without typing its name (required in non-interactive use)"
+
+// guardProtected displays a warning banner and requires confirmation, when the given entry is protected. In
+// non-interactive use the confirmation must be given with the yes argument (the '--yes' flag). It guards all commands
+// handing the entry (or credentials obtained with it) to other programs, not only switching.
+func guardProtected(g *rootOpts, regs *registry.Registries, entry registry.Entry, yes bool) error {
+	ok, err := isProtected(g, regs, entry)
+	if err != nil || !ok {
+		return err
+	}
+
+	name := regs.DisplayName(entry)
+	ui.Banner("red", fmt.Sprintf("WARNING: %q is a PROTECTED entry", name))
+	if yes {
+		return nil
+	}
+	if !ui.Interactive() {
+		return fmt.Errorf("entry %q is protected; If that is intended confirm with '--yes' flag", name)
+	}
+	confirmed, err := ui.ConfirmByTyping("Entry is protected", name)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return fmt.Errorf("aborted")
+	}
+	return nil
+}
+
+// scheduleRevert starts a detached process, that switches back to the safe entry after the configured timeout (if the
+// protected entry is still in use by then).
+func scheduleRevert(g *rootOpts, regs *registry.Registries, entry registry.Entry) error {
+	ok, err := isProtected(g, regs, entry)
+	if err != nil || !ok {
+		return err
+	}
+	cfg, err := g.settings()
+	if err != nil {
+		return err
+	}
+	after := cfg.RevertAfter()
+	if after <= 0 || cfg.Protection.SafeEntry == "" {
+		return nil
+	}
+	safe, err := regs.Lookup(cfg.Protection.SafeEntry)
+	if err != nil {
+		return fmt.Errorf("setting %q: %w", "protection.safe-entry", err)
+	}
+
+	hash, err := registry.Hash(entry.Path)
+	if err != nil {
+		return err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot obtain the kubeconfig executable path: %w", err)
+	}
+	args := []string{"revert-protected", "--after", after.String(), "--expect", hex.EncodeToString(hash), safe.QualifiedName()}
+	if g.altRegistryPath != "" {
+		args = append(args, "--registry", g.altRegistryPath)
+	}
+	cmd := exec.Command(self, args...)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("cannot start automatic revert: %w", err)
+	}
+	if err := cmd.Process.Release(); err != nil {
+		return fmt.Errorf("cannot start automatic revert: %w", err)
+	}
+
+	fmt.Printf("Kubectl config file will be switched back to %q in %s.\n", regs.DisplayName(safe), after)
+	return nil
+}
+
+// newRevertProtectedCmd generates a new (hidden) revert-protected command
+func newRevertProtectedCmd(global *rootOpts) *cobra.Command {
+	o := &revertProtectedOpts{}
+
+	cmd := &cobra.Command{
+		Use:    "revert-protected [safe config name]",
+		Short:  "Switch back to the safe entry after timeout",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.safe = args[0]
+			return revertProtectedRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().DurationVar(&o.after, "after", 0, "time to wait before switching back")
+	cmd.Flags().StringVar(&o.expect, "expect", "", "hash of the protected kubectl config file expected to be in use")
+
+	return cmd
+}
+
+type revertProtectedOpts struct {
+	safe   string
+	after  time.Duration
+	expect string
+}
+
+func revertProtectedRun(ctx context.Context, g *rootOpts, o *revertProtectedOpts) error {
+	time.Sleep(o.after)
+
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	safe, err := regs.Lookup(o.safe)
+	if err != nil {
+		return err
+	}
+
+	kcCfgPath, err := g.kubectlConfigPath()
+	if err != nil {
+		return err
+	}
+	kcCfgHash, err := registry.Hash(kcCfgPath)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(kcCfgHash) != o.expect {
+		return nil // switched to something else in the meantime
+	}
+	current, found, err := regs.Find(kcCfgHash)
+	if err != nil {
+		return err
+	}
+
+	var previous *registry.Entry
+	if found {
+		previous = &current
+	}
+	hook, err := applySwitch(ctx, g, safe, previous, kcCfgPath)
+	if err != nil {
+		return err
+	}
+	g.runPostHook(ctx, hookPostSwitch, hook)
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/kubecfg"
+	"github.com/daishe/kubeconfig/registry"
+)
+
+const rewriteLong = `Rewrites cluster settings of selected entries (by name glob patterns, e.g.
+'aws/*', and label selector), for example when clusters move behind a new
+proxy:
+
+  kubeconfig rewrite 'aws/*' --server-from https://old.example.com --server-to https://new.example.com
+  kubeconfig rewrite -l env=prod --proxy-url socks5://bastion:1080
+
+Server URLs starting with '--server-from' have that prefix replaced with
+'--server-to'. When '--server-from' is given, '--proxy-url' and
+'--tls-server-name' are set only for clusters with matching servers (for all
+clusters otherwise); an empty value removes the setting.
+
+Only lines holding the changed settings are edited; the rest of the file
+(including comments) is kept as is. Files using flow style mappings are encoded
+again instead, which keeps comments, but not indentation. Changes are shown as
+a diff of the file as it will be written and have to be confirmed (or forced
+with '--yes'). Entries are replaced atomically (their previous content is moved
+to the trash).`
+
+// newRewriteCmd generates a new rewrite command.
+func newRewriteCmd(global *rootOpts) *cobra.Command {
+	o := &rewriteOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "rewrite [name patterns...]",
+		Short: "Rewrite server URLs and proxies across entries",
+		Long:  rewriteLong,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.patterns = args
+			o.setProxyURL = cmd.Flags().Changed("proxy-url")
+			o.setTLSServerName = cmd.Flags().Changed("tls-server-name")
+			return rewriteRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "rewrite only entries matching the label selector (e.g. 'env=prod')")
+	cmd.Flags().StringVar(&o.serverFrom, "server-from", "", "prefix of server URLs to be replaced")
+	cmd.Flags().StringVar(&o.serverTo, "server-to", "", "replacement of the '--server-from' prefix")
+	cmd.Flags().StringVar(&o.proxyURL, "proxy-url", "", "proxy URL set for clusters")
+	cmd.Flags().StringVar(&o.tlsServerName, "tls-server-name", "", "server name used for TLS verification set for clusters")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "only show the diff of changes")
+	cmd.Flags().BoolVarP(&o.yes, "yes", "y", false, "do not ask for confirmation")
+
+	return cmd
+}
+
+type rewriteOpts struct {
+	patterns         []string
+	selector         string
+	serverFrom       string
+	serverTo         string
+	proxyURL         string
+	setProxyURL      bool
+	tlsServerName    string
+	setTLSServerName bool
+	dryRun           bool
+	yes              bool
+}
+
+type rewriteChange struct {
+	entry   registry.Entry
+	content []byte
+}
+
+func rewriteRun(ctx context.Context, g *rootOpts, o *rewriteOpts) error {
+	if (o.serverFrom == "") != (o.serverTo == "") {
+		return fmt.Errorf("flags '--server-from' and '--server-to' must be used together")
+	}
+	if o.serverFrom == "" && !o.setProxyURL && !o.setTLSServerName {
+		return fmt.Errorf("nothing to rewrite; Use '--server-from' and '--server-to', '--proxy-url' or '--tls-server-name' flags")
+	}
+
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	sel, err := registry.ParseSelector(o.selector)
+	if err != nil {
+		return err
+	}
+	ls, err := regs.List(sel)
+	if err != nil {
+		return err
+	}
+
+	changes := []rewriteChange{}
+	for _, e := range ls {
+		if !matchesAnyGlob(o.patterns, e) {
+			continue
+		}
+		before, err := os.ReadFile(e.Path)
+		if err != nil {
+			return fmt.Errorf("cannot read file %q: %w", e.Path, err)
+		}
+		after, changed, err := kubecfg.RewriteClusters(before, func(c *kubecfg.Cluster) { rewriteCluster(c, o) })
+		if err != nil || !changed {
+			continue // not a valid kubectl config or no matching clusters, so there is nothing to rewrite
+		}
+
+		name := regs.DisplayName(e)
+		if err := regs.CheckWritable(e); err != nil {
+			fmt.Printf("Skipping %q: %v\n", name, err)
+			continue
+		}
+		for _, l := range ui.Diff(name, name+" (rewritten)", string(before), string(after)) {
+			fmt.Println(l)
+		}
+		changes = append(changes, rewriteChange{entry: e, content: after})
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No matching clusters, nothing to rewrite.")
+		return nil
+	}
+	if o.dryRun {
+		return nil
+	}
+	if !o.yes {
+		if !ui.Interactive() {
+			return fmt.Errorf("rewriting entries requires confirmation; If that is intended confirm with '--yes' flag")
+		}
+		ok, err := ui.Confirm(fmt.Sprintf("Rewrite %d entries", len(changes)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	for _, c := range changes {
+		hook := hookEnv{to: c.entry.QualifiedName(), toPath: c.entry.Path}
+		if err := g.runHook(ctx, hookPreRewrite, hook); err != nil {
+			return err
+		}
+		if err := regs.Write(c.entry, bytes.NewReader(c.content), true); err != nil {
+			return lockHint(err)
+		}
+		g.runPostHook(ctx, hookPostRewrite, hook)
+	}
+	fmt.Fprintf(os.Stderr, "Rewritten %d entries.\n", len(changes))
+	return nil
+}
+
+// matchesAnyGlob reports whether the entry name (or its qualified name) matches any of the given glob patterns. No
+// patterns match all entries.
+func matchesAnyGlob(patterns []string, e registry.Entry) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if registry.MatchGlob(p, e.Name) || registry.MatchGlob(p, e.QualifiedName()) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteCluster applies requested changes to the given cluster.
+func rewriteCluster(c *kubecfg.Cluster, o *rewriteOpts) {
+	if o.serverFrom != "" {
+		if !strings.HasPrefix(c.Server, o.serverFrom) {
+			return
+		}
+		c.Server = o.serverTo + strings.TrimPrefix(c.Server, o.serverFrom)
+	}
+	if o.setProxyURL {
+		c.ProxyURL = o.proxyURL
+	}
+	if o.setTLSServerName {
+		c.TLSServerName = o.tlsServerName
+	}
+}
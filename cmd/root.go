@@ -16,60 +16,281 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 
 	"github.com/daishe/kubeconfig/cmd/ui"
 	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
 )
 
 const rootLong = `A utility tool to manage, swap currently used, store, etc different kubectl
 config files.`
 
-// newRootCmd generates a new base command with all its subcommands.
-func newRootCmd() *cobra.Command {
+// newRootCmd generates a new base command with all its subcommands. Plugins are added only when the given arguments
+// (without the program name) may need them (see needsPlugins).
+func newRootCmd(args []string) *cobra.Command {
 	o := &rootOpts{}
 
 	cmd := &cobra.Command{
-		Use:   "kubeconfig",
-		Short: "kubeconfig - a utility to swap kubectl config files",
-		Long:  rootLong,
+		Use:     "kubeconfig",
+		Short:   "kubeconfig - a utility to swap kubectl config files",
+		Long:    rootLong,
+		Version: buildInfo().Version,
 	}
 
-	cmd.PersistentFlags().StringVar(&o.altRegistryPath, "registry", "", "override the default registry path")
+	cmd.PersistentFlags().StringVar(&o.altRegistryPath, "registry", "", "override the default registry path (and all configured registries)")
+	cmd.PersistentFlags().BoolVar(&o.noHooks, "no-hooks", false, "do not execute hooks")
 
 	cmd.AddCommand(newAddCmd(o))
+	cmd.AddCommand(newAllowCmd(o))
 	cmd.AddCommand(newCompletionCmd(cmd, o))
 	cmd.AddCommand(newCurrentCmd(o))
+	cmd.AddCommand(newDenyCmd(o))
+	cmd.AddCommand(newDoctorCmd(o))
 	cmd.AddCommand(newEditCmd(o))
+	cmd.AddCommand(newEnvCmd(o))
+	cmd.AddCommand(newExecPluginsCmd(o))
+	cmd.AddCommand(newExecTestCmd(o))
+	cmd.AddCommand(newExportCmd(o))
+	cmd.AddCommand(newExtractCmd(o))
+	cmd.AddCommand(newFlattenCmd(o))
+	cmd.AddCommand(newGcCmd(o))
+	cmd.AddCommand(newHookEnvCmd(o))
+	cmd.AddCommand(newImportCmd(o))
+	cmd.AddCommand(newImportBundleCmd(o))
+	cmd.AddCommand(newInitCmd(o))
+	cmd.AddCommand(newLabelCmd(o))
 	cmd.AddCommand(newListCmd(o))
+	cmd.AddCommand(newLockCmd(o))
+	cmd.AddCommand(newMigrateCmd(o))
+	cmd.AddCommand(newPinCmd(o))
+	cmd.AddCommand(newPluginCmd(cmd, o))
+	cmd.AddCommand(newProtectCmd(o))
+	cmd.AddCommand(newRevertProtectedCmd(o))
+	cmd.AddCommand(newRewriteCmd(o))
 	cmd.AddCommand(newSaveCmd(o))
+	cmd.AddCommand(newServeCmd(o))
+	cmd.AddCommand(newSettingsCmd(o))
 	cmd.AddCommand(newShowCmd(o))
 	cmd.AddCommand(newSwitchCmd(o))
+	cmd.AddCommand(newTrashCmd(o))
+	cmd.AddCommand(newTuiCmd(o))
+	cmd.AddCommand(newUnlockCmd(o))
+	cmd.AddCommand(newUnpinCmd(o))
+	cmd.AddCommand(newUnprotectCmd(o))
+	cmd.AddCommand(newVersionCmd(o))
+	cmd.AddCommand(newWatchCmd(o))
+
+	if needsPlugins(cmd, args) {
+		addPluginCmds(cmd, o)
+	}
 
 	return cmd
 }
 
 type rootOpts struct {
 	altRegistryPath string
+	noHooks         bool
+
+	cfg  *settings.Settings
+	regs *registry.Registries
+}
+
+// settings returns the kubeconfig tool settings (loaded on the first use).
+func (o *rootOpts) settings() (*settings.Settings, error) {
+	if o.cfg == nil {
+		cfg, _, err := settings.Load()
+		if err != nil {
+			return nil, err
+		}
+		o.cfg = cfg
+	}
+	return o.cfg, nil
 }
 
+// registries returns the set of configured registries. The '--registry' flag overrides them with a single registry.
+func (o *rootOpts) registries() (*registry.Registries, error) {
+	if o.regs != nil {
+		return o.regs, nil
+	}
+
+	cfg, err := o.settings()
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []*registry.Source
+	writeTo := ""
+	if o.altRegistryPath != "" || len(cfg.Registries) == 0 {
+		path, err := o.regPath()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, &registry.Source{Name: defaultRegistryName, Path: path})
+	} else {
+		for _, r := range cfg.Registries {
+			path, err := settings.ExpandPath(r.Path)
+			if err != nil {
+				return nil, err
+			}
+			if path, err = registry.OverrodePath(path); err != nil {
+				return nil, err
+			}
+			sources = append(sources, &registry.Source{Name: r.Name, Path: path, Priority: r.Priority, ReadOnly: r.ReadOnly})
+		}
+		writeTo = cfg.WriteRegistry
+	}
+
+	regs, err := registry.NewRegistries(sources, writeTo)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range regs.Sources() {
+		if err := registry.CheckFormat(s); err != nil {
+			return nil, err
+		}
+	}
+	regs.SetTrashMaxAge(cfg.TrashMaxAge())
+	o.regs = regs
+	return regs, nil
+}
+
+// defaultRegistryName is the name of the registry used, when no named registries are configured.
+const defaultRegistryName = "default"
+
+// regPath returns the path of the single registry used, when no named registries are configured.
 func (o *rootOpts) regPath() (string, error) {
 	if o.altRegistryPath != "" {
 		return registry.OverrodePath(o.altRegistryPath)
 	}
+	cfg, err := o.settings()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Registry != "" {
+		path, err := settings.ExpandPath(cfg.Registry)
+		if err != nil {
+			return "", err
+		}
+		return registry.OverrodePath(path)
+	}
 	return registry.Path()
 }
 
+func (o *rootOpts) kubectlConfigPath() (string, error) {
+	cfg, err := o.settings()
+	if err != nil {
+		return "", err
+	}
+	if cfg.KubectlConfig != "" {
+		path, err := settings.ExpandPath(cfg.KubectlConfig)
+		if err != nil {
+			return "", err
+		}
+		return registry.OverrodePath(path)
+	}
+	return registry.KubectlConfigPath()
+}
+
+// editor returns the editor to use - the provided override, the one from settings or described by the ${EDITOR}
+// environment variable.
+func (o *rootOpts) editor(override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	cfg, err := o.settings()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Editor != "" {
+		return cfg.Editor, nil
+	}
+	e, ok := os.LookupEnv("EDITOR")
+	if !ok {
+		return "", fmt.Errorf("$EDITOR environment vatiabble not set")
+	}
+	return e, nil
+}
+
+// theme returns colors used by the interactive prompts.
+func (o *rootOpts) theme() (ui.Theme, error) {
+	cfg, err := o.settings()
+	if err != nil {
+		return ui.Theme{}, err
+	}
+	return ui.Theme{Active: cfg.Value("colors.active"), Current: cfg.Value("colors.current")}, nil
+}
+
+// output returns the output format to use - the provided override or the one from settings.
+func (o *rootOpts) output(override string) (string, error) {
+	out := override
+	if out == "" { // settings are loaded only when needed, so that the override works even with broken settings
+		cfg, err := o.settings()
+		if err != nil {
+			return "", err
+		}
+		out = cfg.Value("output")
+	}
+	switch out {
+	case settings.OutputText, settings.OutputJSON, settings.OutputYAML:
+		return out, nil
+	}
+	return "", fmt.Errorf("unknown output format %q", out)
+}
+
+// confirm checks the given confirmation policy. It returns nil when the operation may proceed.
+func (o *rootOpts) confirm(policy string, force bool, question string, refusal string) error {
+	cfg, err := o.settings()
+	if err != nil {
+		return err
+	}
+	if force {
+		return nil
+	}
+	switch cfg.Value(policy) {
+	case settings.ConfirmNone:
+		return nil
+	case settings.ConfirmPrompt:
+		if ui.Interactive() {
+			ok, err := ui.Confirm(question)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("aborted")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s", refusal)
+}
+
+// backup stores a copy of the given file in the backup directory, according to settings.
+func (o *rootOpts) backup(name string, path string) error {
+	cfg, err := o.settings()
+	if err != nil {
+		return err
+	}
+	dir, err := settings.BackupDir()
+	if err != nil {
+		return err
+	}
+	_, err = registry.BackupFile(dir, name, path, cfg.BackupKeep())
+	return err
+}
+
 // Execute runs the application. It uses the os.Args[1:] and runs through the commands tree finding appropriate matches for commands and then corresponding flags.
 func Execute(ctx context.Context) {
-	err := newRootCmd().ExecuteContext(ctx)
+	err := newRootCmd(os.Args[1:]).ExecuteContext(ctx)
 	ui.DisplayAndExitOnError(err)
 }
 
 // ExecuteWith runs the application. It uses the provided args and runs through the commands tree finding appropriate matches for commands and then corresponding flags.
 func ExecuteWith(ctx context.Context, args []string) {
-	cmd := newRootCmd()
+	cmd := newRootCmd(args)
 	cmd.SetArgs(args)
 	err := cmd.ExecuteContext(ctx)
 	ui.DisplayAndExitOnError(err)
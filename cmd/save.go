@@ -15,6 +15,11 @@
 package cmd
 
 import (
+	"context"
+	"io"
+	"path/filepath"
+	"time"
+
 	"github.com/spf13/cobra"
 
 	"github.com/daishe/kubeconfig/cmd/ui"
@@ -22,7 +27,10 @@ import (
 )
 
 const saveLong = `Saves the current kubectl config file under the provided name in the kubeconfig
-registry.`
+registry.
+
+When multiple registries are configured, the entry is saved in the write registry,
+unless the name is prefixed with the registry name (e.g. 'personal:aws/prod').`
 
 // newSaveCmd generates a new save command
 func newSaveCmd(global *rootOpts) *cobra.Command {
@@ -36,36 +44,54 @@ func newSaveCmd(global *rootOpts) *cobra.Command {
 		Args:    cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			o.name = args[0]
-			saveRun(global, o)
+			saveRun(cmd.Context(), global, o)
 		},
 	}
 
-	cmd.Flags().BoolP("force", "f", false, "force override, if the entry with the provided name already exists in the registry")
+	cmd.Flags().BoolVarP(&o.force, "force", "f", false, "force override, if the entry with the provided name already exists in the registry")
+	cmd.Flags().BoolVar(&o.flatten, "flatten", false, "embed referenced certificate and key files into the entry")
+	o.expiryOpts.addFlags(cmd.Flags())
 
 	return cmd
 }
 
 type saveOpts struct {
-	name  string
-	force bool
+	expiryOpts
+	name    string
+	force   bool
+	flatten bool
 }
 
-func saveRun(g *rootOpts, o *saveOpts) {
-	reg, err := g.regPath()
+func saveRun(ctx context.Context, g *rootOpts, o *saveOpts) {
+	expires, err := o.expiry(time.Now())
+	ui.DisplayAndExitOnError(err)
+
+	regs, err := g.registries()
 	ui.DisplayAndExitOnError(err)
 
-	kcCfgPath, err := registry.KubectlConfigPath()
+	entry, err := regs.Target(o.name)
 	ui.DisplayAndExitOnError(err)
 
+	kcCfgPath, err := g.kubectlConfigPath()
+	ui.DisplayAndExitOnError(err)
+
+	hook := hookEnv{fromPath: kcCfgPath, to: entry.QualifiedName(), toPath: entry.Path}
+
 	kcCgf, err := registry.Read(kcCfgPath)
 	ui.DisplayAndExitOnError(err)
 	defer kcCgf.Close()
 
-	if o.force {
-		err = registry.ForceWrite(registry.NameToPath(reg, o.name), kcCgf)
-		ui.DisplayAndExitOnError(err)
-	} else {
-		err = registry.Write(registry.NameToPath(reg, o.name), kcCgf)
+	var content io.Reader = kcCgf
+	if o.flatten {
+		content, err = flattenContent(kcCgf, filepath.Dir(kcCfgPath))
 		ui.DisplayAndExitOnError(err)
 	}
+
+	err = writeEntry(g, regs, entry, content, o.force, g.preHook(ctx, hookPreSave, hook))
+	ui.DisplayAndExitOnError(err)
+
+	err = setExpiry(regs, entry, expires)
+	ui.DisplayAndExitOnError(err)
+
+	g.runPostHook(ctx, hookPostSave, hook)
 }
@@ -0,0 +1,122 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/state"
+)
+
+// Entries sort orders.
+const (
+	sortByName      = "name"
+	sortByRecent    = "recent"
+	sortByFrequent  = "frequent"
+	sortByFavourite = "favourite"
+)
+
+// selectEntry presents the interactive prompt with entries matching the given selector, favourites first, then the
+// most recently used ones. With tree set, the prompt presents entries as a hierarchy of folders.
+func selectEntry(g *rootOpts, regs *registry.Registries, msg string, selector string, tree bool) (registry.Entry, error) {
+	kcCfgPath, err := g.kubectlConfigPath()
+	if err != nil {
+		return registry.Entry{}, err
+	}
+	kcCfgHash, err := registry.Hash(kcCfgPath)
+	if err != nil {
+		return registry.Entry{}, err
+	}
+	theme, err := g.theme()
+	if err != nil {
+		return registry.Entry{}, err
+	}
+	sel, err := registry.ParseSelector(selector)
+	if err != nil {
+		return registry.Entry{}, err
+	}
+
+	ls, cmp, err := regs.ListWithCmp(kcCfgHash, sel)
+	if err != nil {
+		return registry.Entry{}, err
+	}
+	st, err := state.Load()
+	if err != nil {
+		return registry.Entry{}, err
+	}
+	if err := sortEntries(ls, cmp, sortByFavourite, st); err != nil {
+		return registry.Entry{}, err
+	}
+
+	return ui.SelectPrompt(regs, msg, ls, cmp, theme, tree)
+}
+
+// sortEntries sorts entries (and corresponding comparison results) in the requested order. Entries are expected to be
+// initially sorted by name.
+func sortEntries(ls []registry.Entry, cmp []bool, order string, st *state.State) error {
+	var less func(a, b registry.Entry) bool
+	switch order {
+	case sortByName:
+		return nil
+	case sortByRecent:
+		less = func(a, b registry.Entry) bool {
+			return st.UsageOf(a.QualifiedName()).LastUsed.After(st.UsageOf(b.QualifiedName()).LastUsed)
+		}
+	case sortByFrequent:
+		less = func(a, b registry.Entry) bool {
+			return st.UsageOf(a.QualifiedName()).Count > st.UsageOf(b.QualifiedName()).Count
+		}
+	case sortByFavourite:
+		less = func(a, b registry.Entry) bool {
+			pa, pb := st.PinIndex(a.QualifiedName()), st.PinIndex(b.QualifiedName())
+			switch {
+			case pa >= 0 && pb >= 0:
+				return pa < pb
+			case pa >= 0 || pb >= 0:
+				return pa >= 0
+			}
+			return st.UsageOf(a.QualifiedName()).LastUsed.After(st.UsageOf(b.QualifiedName()).LastUsed)
+		}
+	default:
+		return fmt.Errorf("unknown sort order %q", order)
+	}
+
+	idx := make([]int, len(ls))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return less(ls[idx[i]], ls[idx[j]]) })
+
+	sortedLs := make([]registry.Entry, len(ls))
+	sortedCmp := make([]bool, len(cmp))
+	for i, j := range idx {
+		sortedLs[i], sortedCmp[i] = ls[j], cmp[j]
+	}
+	copy(ls, sortedLs)
+	copy(cmp, sortedCmp)
+	return nil
+}
+
+// recordUse records the use of the given entry in the usage statistics.
+func recordUse(entry registry.Entry) error {
+	return state.Update(func(s *state.State) error {
+		s.Used(entry.QualifiedName(), time.Now())
+		return nil
+	})
+}
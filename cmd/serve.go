@@ -0,0 +1,394 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/kubecfg"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
+	"github.com/daishe/kubeconfig/state"
+)
+
+//go:embed openapi.yaml
+var openAPI []byte
+
+const serveLong = `Serves a local HTTP API with JSON responses (for editor extensions, dashboards
+etc) on a Unix socket, until interrupted. The API allows listing entries, showing
+them (with secrets redacted), reporting the current entry and switching entries.
+Its OpenAPI description is served under '/openapi.yaml'.
+
+Access is controlled by file permissions - the socket is accessible only by its
+owner. The socket is created in the kubeconfig state directory, unless set with
+'--socket'. To query the API, use for example:
+
+  curl --unix-socket <socket path> http://localhost/v1/entries`
+
+// newServeCmd generates a new serve command.
+func newServeCmd(global *rootOpts) *cobra.Command {
+	o := &serveOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a local HTTP API on a Unix socket",
+		Long:  serveLong,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serveRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.socket, "socket", "", "path to the Unix socket, instead of 'kubeconfig.sock' in the state directory")
+
+	return cmd
+}
+
+type serveOpts struct {
+	socket string
+}
+
+func serveRun(ctx context.Context, g *rootOpts, o *serveOpts) error {
+	path := o.socket
+	if path == "" {
+		dir, err := settings.StateDir()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(dir, "kubeconfig.sock")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("cannot create directory %q for socket %q: %w", filepath.Dir(path), path, err)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+	l, err := listenPrivate(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Handler: newAPIHandler(g), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown) //nolint:errcheck
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving API on %q (press Ctrl+C to stop).\n", path)
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving API filed: %w", err)
+	}
+	return nil
+}
+
+// removeStaleSocket removes the socket left by a server, that is no longer running.
+func removeStaleSocket(path string) error {
+	stat, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot stat %q: %w", path, err)
+	}
+	if stat.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("file %q exists and is not a socket", path)
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return fmt.Errorf("another server is already listening on %q", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("cannot remove stale socket %q: %w", path, err)
+	}
+	return nil
+}
+
+// apiHandler serves the local HTTP API. Requests are handled one at a time, with registries and settings loaded anew for
+// each of them, so that changes made by other processes are visible.
+type apiHandler struct {
+	g   *rootOpts
+	mu  sync.Mutex
+	mux *http.ServeMux
+}
+
+func newAPIHandler(g *rootOpts) http.Handler {
+	h := &apiHandler{g: g, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/openapi.yaml", h.method(http.MethodGet, h.openAPI))
+	h.mux.HandleFunc("/v1/entries", h.method(http.MethodGet, h.entries))
+	h.mux.HandleFunc("/v1/entries/", h.method(http.MethodGet, h.entry))
+	h.mux.HandleFunc("/v1/current", h.method(http.MethodGet, h.current))
+	h.mux.HandleFunc("/v1/switch", h.method(http.MethodPost, h.switchEntry))
+	return h
+}
+
+func (h *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.g.cfg, h.g.regs = nil, nil
+	h.mux.ServeHTTP(w, r)
+}
+
+// apiError is an error with the HTTP status code to respond with.
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e *apiError) Error() string { return e.err.Error() }
+
+func (e *apiError) Unwrap() error { return e.err }
+
+func (h *apiHandler) method(method string, handle func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, apiErrorResponse{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+			return
+		}
+		v, err := handle(r)
+		if err != nil {
+			status := http.StatusInternalServerError
+			ae := &apiError{}
+			if errors.As(err, &ae) {
+				status = ae.status
+			}
+			writeJSON(w, status, apiErrorResponse{Error: err.Error()})
+			return
+		}
+		if b, ok := v.([]byte); ok {
+			w.Header().Set("Content-Type", "application/yaml")
+			w.Write(b) //nolint:errcheck
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+type apiErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v) //nolint:errcheck
+}
+
+func (h *apiHandler) openAPI(r *http.Request) (interface{}, error) {
+	return openAPI, nil
+}
+
+func (h *apiHandler) entries(r *http.Request) (interface{}, error) {
+	regs, err := h.g.registries()
+	if err != nil {
+		return nil, err
+	}
+	kcCfgPath, err := h.g.kubectlConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	kcCfgHash, err := registry.Hash(kcCfgPath)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := registry.ParseSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		return nil, &apiError{http.StatusBadRequest, err}
+	}
+	ls, cmp, err := regs.ListWithCmp(kcCfgHash, sel)
+	if err != nil {
+		return nil, err
+	}
+	st, err := state.Load()
+	if err != nil {
+		return nil, err
+	}
+	order := r.URL.Query().Get("sort")
+	if order == "" {
+		order = sortByName
+	}
+	if err := sortEntries(ls, cmp, order, st); err != nil {
+		return nil, &apiError{http.StatusBadRequest, err}
+	}
+	return listItems(regs, ls, cmp, registry.DisplayNames(ls), st)
+}
+
+type apiEntry struct {
+	Name     string                 `json:"name"`
+	Registry string                 `json:"registry"`
+	Summary  []string               `json:"summary"`
+	Config   map[string]interface{} `json:"config"`
+}
+
+// lookupEntry resolves the entry name given in a request. Invalid names (e.g. naming files outside of registries or in
+// the trash) are rejected as a bad request.
+func lookupEntry(regs *registry.Registries, name string) (registry.Entry, error) {
+	_, n := regs.SplitName(name)
+	if err := registry.ValidateName(n); err != nil {
+		return registry.Entry{}, &apiError{http.StatusBadRequest, err}
+	}
+	entry, err := regs.Lookup(name)
+	if err != nil {
+		return registry.Entry{}, &apiError{http.StatusNotFound, err}
+	}
+	return entry, nil
+}
+
+func (h *apiHandler) entry(r *http.Request) (interface{}, error) {
+	regs, err := h.g.registries()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := lookupEntry(regs, strings.TrimPrefix(r.URL.Path, "/v1/entries/"))
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := kubecfg.ReadFile(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Redact()
+	b, err := cfg.Encode()
+	if err != nil {
+		return nil, err
+	}
+	res := apiEntry{Name: regs.DisplayName(entry), Registry: entry.Source.Name, Summary: ui.ConfigSummary(cfg, time.Now())}
+	if err := yaml.Unmarshal(b, &res.Config); err != nil {
+		return nil, fmt.Errorf("cannot encode config: %w", err)
+	}
+	return res, nil
+}
+
+func (h *apiHandler) current(r *http.Request) (interface{}, error) {
+	regs, err := h.g.registries()
+	if err != nil {
+		return nil, err
+	}
+	kcCfgPath, err := h.g.kubectlConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	kcCfgHash, err := registry.Hash(kcCfgPath)
+	if err != nil {
+		return nil, err
+	}
+	current, found, err := regs.Find(kcCfgHash)
+	if err != nil {
+		return nil, err
+	}
+	item := currentItem{Found: found, Path: kcCfgPath}
+	if found {
+		item.Name, item.Registry = regs.DisplayName(current), current.Source.Name
+	}
+	return item, nil
+}
+
+type apiSwitchRequest struct {
+	Name             string `json:"name"`
+	Force            bool   `json:"force"`
+	ConfirmProtected bool   `json:"confirmProtected"`
+}
+
+type apiSwitchResponse struct {
+	Name     string `json:"name"`
+	Registry string `json:"registry"`
+	Previous string `json:"previous,omitempty"`
+}
+
+func (h *apiHandler) switchEntry(r *http.Request) (interface{}, error) {
+	req := apiSwitchRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, &apiError{http.StatusBadRequest, fmt.Errorf("cannot parse request: %w", err)}
+	}
+
+	regs, err := h.g.registries()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := lookupEntry(regs, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	kcCfgPath, err := h.g.kubectlConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	kcCfgHash, err := registry.Hash(kcCfgPath)
+	if err != nil {
+		return nil, err
+	}
+	current, found, err := regs.Find(kcCfgHash)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := h.g.settings()
+	if err != nil {
+		return nil, err
+	}
+	if !found && !req.Force && cfg.Value("confirm.switch-unknown") != settings.ConfirmNone {
+		return nil, &apiError{http.StatusConflict, errors.New("the current kubectl config do not exists in the registry and switching config files will override it; If that is intended set 'force'")}
+	}
+	protected, err := isProtected(h.g, regs, entry)
+	if err != nil {
+		return nil, err
+	}
+	if protected && !req.ConfirmProtected {
+		return nil, &apiError{http.StatusForbidden, fmt.Errorf("entry %q is protected; If that is intended set 'confirmProtected'", regs.DisplayName(entry))}
+	}
+
+	var previous *registry.Entry
+	if found {
+		previous = &current
+	}
+	hook, err := applySwitch(r.Context(), h.g, entry, previous, kcCfgPath)
+	if err != nil {
+		return nil, err
+	}
+	h.g.runPostHook(r.Context(), hookPostSwitch, hook)
+	if err := scheduleRevert(h.g, regs, entry); err != nil {
+		return nil, err
+	}
+
+	res := apiSwitchResponse{Name: regs.DisplayName(entry), Registry: entry.Source.Name}
+	if found {
+		res.Previous = regs.DisplayName(current)
+	}
+	return res, nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daishe/kubeconfig/registry"
+)
+
+const testConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[1]s.example.com
+    certificate-authority-data: Q0EgREFUQQ==
+users:
+- name: admin
+  user:
+    token: secret-token
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: admin
+current-context: %[1]s
+`
+
+// newTestAPI starts the API server backed by a temporary home directory with the registry holding 'dev', 'prod'
+// (protected, labelled env=prod) and 'team/app' (labelled env=dev) entries, and the kubectl config matching 'dev'.
+func newTestAPI(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv(envEntry, "")
+
+	write := func(path string, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"dev", "prod", "team/app"} {
+		write(filepath.Join(home, ".kubeconfig", filepath.FromSlash(name)), fmt.Sprintf(testConfig, filepath.Base(name)))
+	}
+	write(filepath.Join(home, ".kube", "config"), fmt.Sprintf(testConfig, "dev"))
+
+	g := &rootOpts{}
+	regs, err := g.registries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, meta := range map[string]*registry.EntryMetadata{
+		"prod":     {Labels: map[string]string{"env": "prod"}, Protected: true},
+		"team/app": {Labels: map[string]string{"env": "dev"}},
+	} {
+		e, err := regs.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := regs.SetMetadata(e, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := httptest.NewServer(newAPIHandler(&rootOpts{noHooks: true}))
+	t.Cleanup(srv.Close)
+	return srv, home
+}
+
+// apiCall sends the request to the API server and decodes the JSON response into v (if not nil). It returns the
+// response status code.
+func apiCall(t *testing.T, srv *httptest.Server, method string, path string, body string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		if err := json.Unmarshal(b, v); err != nil {
+			t.Fatalf("%s %s: cannot decode response %q: %v", method, path, b, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPIEntries(t *testing.T) {
+	srv, _ := newTestAPI(t)
+
+	items := []listItem{}
+	if status := apiCall(t, srv, http.MethodGet, "/v1/entries", "", &items); status != http.StatusOK {
+		t.Fatalf("GET /v1/entries status = %d, want %d", status, http.StatusOK)
+	}
+	names := []string{}
+	for _, it := range items {
+		names = append(names, it.Name)
+		if it.Current != (it.Name == "dev") {
+			t.Errorf("entry %q current = %v, want %v", it.Name, it.Current, it.Name == "dev")
+		}
+	}
+	if got, want := strings.Join(names, ","), "dev,prod,team/app"; got != want {
+		t.Errorf("GET /v1/entries names = %s, want %s", got, want)
+	}
+
+	items = []listItem{}
+	if status := apiCall(t, srv, http.MethodGet, "/v1/entries?selector=env%3Dprod", "", &items); status != http.StatusOK {
+		t.Fatalf("GET /v1/entries?selector=env=prod status = %d, want %d", status, http.StatusOK)
+	}
+	if len(items) != 1 || items[0].Name != "prod" || items[0].Labels["env"] != "prod" {
+		t.Errorf("GET /v1/entries?selector=env=prod = %+v, want only entry \"prod\" with its labels", items)
+	}
+
+	if status := apiCall(t, srv, http.MethodGet, "/v1/entries?selector=env%3D%3D%3D", "", nil); status != http.StatusBadRequest {
+		t.Errorf("GET /v1/entries with an invalid selector status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestAPIEntry(t *testing.T) {
+	srv, _ := newTestAPI(t)
+
+	res := apiEntry{}
+	if status := apiCall(t, srv, http.MethodGet, "/v1/entries/team/app", "", &res); status != http.StatusOK {
+		t.Fatalf("GET /v1/entries/team/app status = %d, want %d", status, http.StatusOK)
+	}
+	if res.Name != "team/app" || res.Registry != defaultRegistryName {
+		t.Errorf("GET /v1/entries/team/app name and registry = %q %q, want %q %q", res.Name, res.Registry, "team/app", defaultRegistryName)
+	}
+	b, err := json.Marshal(res.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"secret-token", "Q0EgREFUQQ=="} {
+		if bytes.Contains(b, []byte(s)) {
+			t.Errorf("GET /v1/entries/team/app config contains secret %q: %s", s, b)
+		}
+	}
+	for _, s := range []string{`"token":"REDACTED"`, `"certificate-authority-data":"DATA+OMITTED"`, `"server":"https://app.example.com"`} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Errorf("GET /v1/entries/team/app config does not contain %s: %s", s, b)
+		}
+	}
+
+	if status := apiCall(t, srv, http.MethodGet, "/v1/entries/missing", "", nil); status != http.StatusNotFound {
+		t.Errorf("GET /v1/entries/missing status = %d, want %d", status, http.StatusNotFound)
+	}
+	if status := apiCall(t, srv, http.MethodGet, "/v1/entries/.trash/x/prod", "", nil); status != http.StatusBadRequest {
+		t.Errorf("GET /v1/entries/.trash/x/prod status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestAPICurrent(t *testing.T) {
+	srv, home := newTestAPI(t)
+
+	item := currentItem{}
+	if status := apiCall(t, srv, http.MethodGet, "/v1/current", "", &item); status != http.StatusOK {
+		t.Fatalf("GET /v1/current status = %d, want %d", status, http.StatusOK)
+	}
+	want := currentItem{Name: "dev", Registry: defaultRegistryName, Found: true, Path: filepath.Join(home, ".kube", "config")}
+	if item != want {
+		t.Errorf("GET /v1/current = %+v, want %+v", item, want)
+	}
+}
+
+func TestAPISwitch(t *testing.T) {
+	srv, home := newTestAPI(t)
+	kcCfgPath := filepath.Join(home, ".kube", "config")
+
+	res := apiSwitchResponse{}
+	if status := apiCall(t, srv, http.MethodPost, "/v1/switch", `{"name": "team/app"}`, &res); status != http.StatusOK {
+		t.Fatalf("POST /v1/switch status = %d, want %d", status, http.StatusOK)
+	}
+	want := apiSwitchResponse{Name: "team/app", Registry: defaultRegistryName, Previous: "dev"}
+	if res != want {
+		t.Errorf("POST /v1/switch = %+v, want %+v", res, want)
+	}
+	b, err := os.ReadFile(kcCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("https://app.example.com")) {
+		t.Errorf("kubectl config after switching is not the content of \"team/app\":\n%s", b)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"unknown entry", http.MethodPost, `{"name": "missing"}`, http.StatusNotFound},
+		{"protected entry", http.MethodPost, `{"name": "prod"}`, http.StatusForbidden},
+		{"path traversal", http.MethodPost, `{"name": "../../.ssh/id_rsa"}`, http.StatusBadRequest},
+		{"qualified path traversal", http.MethodPost, `{"name": "default:../.kube/config"}`, http.StatusBadRequest},
+		{"trashed entry", http.MethodPost, `{"name": ".trash/x/prod"}`, http.StatusBadRequest},
+		{"invalid request", http.MethodPost, `{"name":`, http.StatusBadRequest},
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := apiErrorResponse{}
+			if status := apiCall(t, srv, tt.method, "/v1/switch", tt.body, &res); status != tt.status {
+				t.Errorf("%s /v1/switch %s status = %d, want %d", tt.method, tt.body, status, tt.status)
+			}
+			if res.Error == "" {
+				t.Errorf("%s /v1/switch %s returned no error message", tt.method, tt.body)
+			}
+		})
+	}
+
+	// the current kubectl config matches no entry, so switching would lose it
+	if err := os.WriteFile(kcCfgPath, []byte("apiVersion: v1\nkind: Config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if status := apiCall(t, srv, http.MethodPost, "/v1/switch", `{"name": "dev"}`, nil); status != http.StatusConflict {
+		t.Errorf("POST /v1/switch with unknown current config status = %d, want %d", status, http.StatusConflict)
+	}
+	if status := apiCall(t, srv, http.MethodPost, "/v1/switch", `{"name": "dev", "force": true}`, nil); status != http.StatusOK {
+		t.Errorf("POST /v1/switch with unknown current config and force status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestAPIMethodNotAllowed(t *testing.T) {
+	srv, _ := newTestAPI(t)
+
+	for _, path := range []string{"/v1/entries", "/v1/entries/dev", "/v1/current"} {
+		if status := apiCall(t, srv, http.MethodPost, path, "{}", nil); status != http.StatusMethodNotAllowed {
+			t.Errorf("POST %s status = %d, want %d", path, status, http.StatusMethodNotAllowed)
+		}
+	}
+}
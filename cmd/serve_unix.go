@@ -0,0 +1,21 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// listenPrivate creates a Unix socket accessible only by its owner. The umask is narrowed while creating the socket, so
+// that there is no window during which it is accessible by others.
+func listenPrivate(path string) (net.Listener, error) {
+	old := syscall.Umask(0077)
+	l, err := net.Listen("unix", path)
+	syscall.Umask(old)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %q: %w", path, err)
+	}
+	return l, nil
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+)
+
+// listenPrivate creates a Unix socket. On Windows, access is controlled by permissions of the directory holding it.
+func listenPrivate(path string) (net.Listener, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %q: %w", path, err)
+	}
+	return l, nil
+}
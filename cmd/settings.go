@@ -0,0 +1,179 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/settings"
+)
+
+const settingsLong = `Manages the kubeconfig tool settings.
+
+Settings are stored in the '${XDG_CONFIG_HOME}/kubeconfig/config.yaml' file
+(defaulting to '${HOME}/.config/kubeconfig/config.yaml'). Every setting can be
+overridden by the corresponding environment variable, e.g. 'registry' by the
+${KUBECONFIG_REGISTRY} and 'confirm.switch-unknown' by the
+${KUBECONFIG_CONFIRM_SWITCH_UNKNOWN}.`
+
+// newSettingsCmd generates a new settings command
+func newSettingsCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "settings",
+		Short:   "Manage kubeconfig settings",
+		Long:    settingsLong,
+		Aliases: []string{"setting", "cfg"},
+	}
+
+	cmd.AddCommand(newSettingsGetCmd(global))
+	cmd.AddCommand(newSettingsSetCmd(global))
+	cmd.AddCommand(newSettingsListCmd(global))
+
+	return cmd
+}
+
+// newSettingsGetCmd generates a new settings get command
+func newSettingsGetCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "get [setting]",
+		Short:     "Show the effective value of a setting",
+		Long:      `Shows the effective value of the requested setting, including overrides from environment variables and defaults.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: settings.Names(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return settingsGetRun(global, args[0])
+		},
+	}
+
+	return cmd
+}
+
+func settingsGetRun(g *rootOpts, name string) error {
+	if _, err := settings.LookupKey(name); err != nil {
+		return err
+	}
+	cfg, err := g.settings()
+	if err != nil {
+		return err
+	}
+	fmt.Println(cfg.Value(name))
+	return nil
+}
+
+// newSettingsSetCmd generates a new settings set command
+func newSettingsSetCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "set [setting] [value]",
+		Short:     "Set a value of a setting in the settings file",
+		Long:      `Sets the value of the requested setting in the settings file. An empty value resets the setting to its default.`,
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: settings.Names(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return settingsSetRun(global, args[0], args[1])
+		},
+	}
+
+	return cmd
+}
+
+func settingsSetRun(g *rootOpts, name string, value string) error {
+	path, err := settings.Path()
+	if err != nil {
+		return err
+	}
+	cfg, err := settings.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Set(name, value); err != nil {
+		return err
+	}
+	if err := settings.WriteFile(path, cfg); err != nil {
+		return err
+	}
+
+	if k, _ := settings.LookupKey(name); k != nil {
+		if _, ok := os.LookupEnv(k.Env()); ok {
+			fmt.Fprintf(os.Stderr, "Warning: setting %q is overridden by the ${%s} environment variable.\n", name, k.Env())
+		}
+	}
+	return nil
+}
+
+// newSettingsListCmd generates a new settings list command
+func newSettingsListCmd(global *rootOpts) *cobra.Command {
+	o := &settingsListOpts{}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "Show all settings",
+		Long:    `Shows effective values of all settings together with their sources.`,
+		Aliases: []string{"lst", "ls", "l", "li"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return settingsListRun(global, o)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format (text, json or yaml), instead of the one set in settings")
+
+	return cmd
+}
+
+type settingsListOpts struct {
+	output string
+}
+
+type settingsItem struct {
+	Name   string `json:"name" yaml:"name"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+	Env    string `json:"env" yaml:"env"`
+	Usage  string `json:"usage" yaml:"usage"`
+}
+
+func settingsListRun(g *rootOpts, o *settingsListOpts) error {
+	cfg, sources, err := settings.Load()
+	if err != nil {
+		return err
+	}
+	g.cfg = cfg
+
+	out, err := g.output(o.output)
+	if err != nil {
+		return err
+	}
+
+	items := []settingsItem{}
+	for _, k := range settings.Keys() {
+		items = append(items, settingsItem{Name: k.Name, Value: cfg.Value(k.Name), Source: string(sources[k.Name]), Env: k.Env(), Usage: k.Usage})
+	}
+
+	if out != settings.OutputText {
+		return ui.PrintStructured(out, items)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+	for _, i := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", i.Name, i.Value, i.Source)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Shells supported by 'init', 'hook-env' and 'env' commands.
+const (
+	shellBash       = "bash"
+	shellZsh        = "zsh"
+	shellFish       = "fish"
+	shellPowerShell = "powershell"
+)
+
+// shellNames lists supported shells.
+var shellNames = []string{shellBash, shellZsh, shellFish, shellPowerShell}
+
+// resolveShell returns the given shell name (validated) or, if empty, the shell detected from the environment.
+func resolveShell(name string) (string, error) {
+	if name == "" {
+		return detectShell(), nil
+	}
+	switch name {
+	case "pwsh", "ps":
+		return shellPowerShell, nil
+	}
+	for _, s := range shellNames {
+		if name == s {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported shell %q (expected one of: %s)", name, strings.Join(shellNames, ", "))
+}
+
+// detectShell guesses the shell from the ${SHELL} environment variable (falling back to bash, or PowerShell on Windows).
+func detectShell() string {
+	switch strings.TrimSuffix(filepath.Base(os.Getenv("SHELL")), ".exe") {
+	case "zsh":
+		return shellZsh
+	case "fish":
+		return shellFish
+	case "bash", "sh":
+		return shellBash
+	case "pwsh", "powershell":
+		return shellPowerShell
+	}
+	if os.Getenv("PSModulePath") != "" {
+		return shellPowerShell
+	}
+	return shellBash
+}
+
+// shellExport returns the statement setting and exporting the environment variable in the given shell.
+func shellExport(shell string, name string, value string) string {
+	switch shell {
+	case shellFish:
+		return fmt.Sprintf("set -gx %s %s;", name, shellQuote(shell, value))
+	case shellPowerShell:
+		return fmt.Sprintf("$Env:%s = %s;", name, shellQuote(shell, value))
+	}
+	return fmt.Sprintf("export %s=%s;", name, shellQuote(shell, value))
+}
+
+// shellUnset returns the statement removing the environment variable in the given shell.
+func shellUnset(shell string, name string) string {
+	switch shell {
+	case shellFish:
+		return fmt.Sprintf("set -e %s;", name)
+	case shellPowerShell:
+		return fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue;", name)
+	}
+	return fmt.Sprintf("unset %s;", name)
+}
+
+// shellQuote quotes the value as a single word in the given shell.
+func shellQuote(shell string, value string) string {
+	switch shell {
+	case shellFish:
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+	case shellPowerShell:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
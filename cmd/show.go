@@ -48,34 +48,33 @@ func newShowCmd(global *rootOpts) *cobra.Command {
 			showRun(global, o)
 		},
 	}
+	cmd.Flags().BoolVar(&o.tree, "tree", false, "present entries in the interactive prompt as a hierarchy of folders")
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "filter entries presented in the interactive prompt by labels (e.g. 'env=prod,team!=infra')")
 
 	return cmd
 }
 
 type showOpts struct {
 	name        string
+	selector    string
+	tree        bool
 	interactive bool
 }
 
 func showRun(g *rootOpts, o *showOpts) {
-	reg, err := g.regPath()
+	regs, err := g.registries()
 	ui.DisplayAndExitOnError(err)
 
-	path := ""
+	var entry registry.Entry
 	if o.interactive {
-		kcCfgPath, err := registry.KubectlConfigPath()
-		ui.DisplayAndExitOnError(err)
-
-		kcCfgHash, err := registry.Hash(kcCfgPath)
-		ui.DisplayAndExitOnError(err)
-
-		path, err = ui.SelectPrompt(reg, "Which kubectl config file to show", kcCfgHash)
+		entry, err = selectEntry(g, regs, "Which kubectl config file to show", o.selector, o.tree)
 		ui.DisplayAndExitOnError(err)
 	} else {
-		path = registry.NameToPath(reg, o.name)
+		entry, err = regs.Lookup(o.name)
+		ui.DisplayAndExitOnError(err)
 	}
 
-	toShow, err := registry.Read(path)
+	toShow, err := registry.Read(entry.Path)
 	ui.DisplayAndExitOnError(err)
 	defer toShow.Close()
 
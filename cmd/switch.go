@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -24,13 +25,26 @@ import (
 )
 
 const switchLong = `Switches the current kubectl config file to the requested one, by overriding the
-'${HOME}/.kube/config'.
+'${HOME}/.kube/config' (or the kubectl config file set in settings).
 
 If the current kubectl config file is not known to kubeconfig and overriding it
-will loose some inforatmion, the command will fail.
+will loose some inforatmion, the command will fail (unless configured otherwise
+by the 'confirm.switch-unknown' setting). The overridden file is backed up.
+
+Executable 'pre-switch' and 'post-switch' files in the hooks directory (under
+the kubeconfig configuration directory) are executed before and after switching,
+unless the '--no-hooks' flag is used. A failing 'pre-switch' hook aborts switching.
 
 If the kubectl config file is not specified, the command presents an interactive
-list of all files in the registry with an option to select one.`
+list of all files in the registry (favourites first, then the most recently used
+ones) with an option to select one.
+
+When multiple registries are configured, the name may be prefixed with the
+registry name (e.g. 'team:aws/prod'). Otherwise registries are searched in the
+order of their priorities.
+
+Switching to a protected entry (see 'kubeconfig protect --help') requires
+confirmation by typing its name, or the '--yes' flag.`
 
 // newSwitchCmd generates a new switch command
 func newSwitchCmd(global *rootOpts) *cobra.Command {
@@ -48,11 +62,14 @@ func newSwitchCmd(global *rootOpts) *cobra.Command {
 				o.name = args[0]
 				o.interactive = false
 			}
-			switchRun(global, o)
+			switchRun(cmd.Context(), global, o)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&o.force, "force", "f", false, "force switching, ignore overriding not known kubectl config file")
+	cmd.Flags().BoolVarP(&o.yes, "yes", "y", false, protectedYesUsage)
+	cmd.Flags().BoolVar(&o.tree, "tree", false, "present entries in the interactive prompt as a hierarchy of folders")
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "filter entries presented in the interactive prompt by labels (e.g. 'env=prod,team!=infra')")
 
 	return cmd
 }
@@ -60,44 +77,90 @@ func newSwitchCmd(global *rootOpts) *cobra.Command {
 type switchOpts struct {
 	name        string
 	force       bool
+	yes         bool
+	selector    string
+	tree        bool
 	interactive bool
 }
 
-func switchRun(g *rootOpts, o *switchOpts) {
-	reg, err := g.regPath()
+func switchRun(ctx context.Context, g *rootOpts, o *switchOpts) {
+	regs, err := g.registries()
 	ui.DisplayAndExitOnError(err)
 
-	kcCfgPath, err := registry.KubectlConfigPath()
+	kcCfgPath, err := g.kubectlConfigPath()
 	ui.DisplayAndExitOnError(err)
 
 	kcCfgHash, err := registry.Hash(kcCfgPath)
 	ui.DisplayAndExitOnError(err)
 
-	current, found, err := registry.Find(reg, kcCfgHash)
+	current, found, err := regs.Find(kcCfgHash)
 	ui.DisplayAndExitOnError(err)
 
-	if !found && !o.force {
-		ui.DisplayAndExitOnError(fmt.Errorf("the current kubectl config do not exists in the registry and switching config files will override it; If that is intended force with '--force' flag"))
+	if !found {
+		err = g.confirm(
+			"confirm.switch-unknown", o.force,
+			"The current kubectl config do not exists in the registry, override it",
+			"the current kubectl config do not exists in the registry and switching config files will override it; If that is intended force with '--force' flag",
+		)
+		ui.DisplayAndExitOnError(err)
 	}
 
-	path := ""
+	var entry registry.Entry
 	if o.interactive {
-		path, err = ui.SelectPrompt(reg, "Which kubectl config file to switch to", kcCfgHash)
+		entry, err = selectEntry(g, regs, "Which kubectl config file to switch to", o.selector, o.tree)
 		ui.DisplayAndExitOnError(err)
 	} else {
-		path = registry.NameToPath(reg, o.name)
+		entry, err = regs.Lookup(o.name)
+		ui.DisplayAndExitOnError(err)
 	}
 
-	cfg, err := registry.Read(path)
+	err = guardProtected(g, regs, entry, o.yes)
 	ui.DisplayAndExitOnError(err)
-	defer cfg.Close()
 
-	err = registry.ForceWrite(kcCfgPath, cfg)
+	var previous *registry.Entry
+	if found {
+		previous = &current
+	}
+	hook, err := applySwitch(ctx, g, entry, previous, kcCfgPath)
 	ui.DisplayAndExitOnError(err)
 
 	if !found {
-		fmt.Printf("Successfully switched from unknown kubectl config file to %q.\n", registry.PathToName(reg, path))
+		fmt.Printf("Successfully switched from unknown kubectl config file to %q.\n", regs.DisplayName(entry))
 	} else {
-		fmt.Printf("Successfully switched from %q to %q.\n", registry.PathToName(reg, current), registry.PathToName(reg, path))
+		fmt.Printf("Successfully switched from %q to %q.\n", regs.DisplayName(current), regs.DisplayName(entry))
+	}
+	g.runPostHook(ctx, hookPostSwitch, hook)
+
+	err = scheduleRevert(g, regs, entry)
+	ui.DisplayAndExitOnError(err)
+}
+
+// applySwitch overrides the kubectl config file with the given entry (backing it up first, when it is not known to
+// the registry) and records the use of the entry. The pre-switch hook is executed before, the post-switch hook is left
+// to the caller (with the returned hook environment).
+func applySwitch(ctx context.Context, g *rootOpts, entry registry.Entry, previous *registry.Entry, kcCfgPath string) (hookEnv, error) {
+	hook := hookEnv{fromPath: kcCfgPath, to: entry.QualifiedName(), toPath: entry.Path}
+	if previous != nil {
+		hook.from = previous.QualifiedName()
+	}
+	if err := g.runHook(ctx, hookPreSwitch, hook); err != nil {
+		return hook, err
+	}
+
+	if previous == nil {
+		if err := g.backup("kubectl-config", kcCfgPath); err != nil {
+			return hook, err
+		}
+	}
+
+	cfg, err := registry.Read(entry.Path)
+	if err != nil {
+		return hook, err
+	}
+	defer cfg.Close()
+
+	if err := registry.ForceWrite(kcCfgPath, cfg); err != nil {
+		return hook, err
 	}
+	return hook, recordUse(entry)
 }
@@ -0,0 +1,254 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
+)
+
+const trashLong = `Manages the trash, where removed and overwritten entries are moved to.
+
+Every registry has its own trash (the hidden '.trash' directory). Items older
+than the 'trash.max-age' setting are purged automatically.
+
+The trash is the only copy of previous content of entries kept by kubeconfig -
+entries are not additionally backed up (the backup directory holds only backups
+of the active kubectl config and archives of migrated registries).`
+
+// newTrashCmd generates a new trash command
+func newTrashCmd(global *rootOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "trash",
+		Short:   "Manage removed and overwritten entries",
+		Long:    trashLong,
+		Aliases: []string{"bin"},
+	}
+
+	cmd.AddCommand(newTrashListCmd(global))
+	cmd.AddCommand(newTrashRestoreCmd(global))
+	cmd.AddCommand(newTrashEmptyCmd(global))
+
+	return cmd
+}
+
+// newTrashListCmd generates a new trash list command
+func newTrashListCmd(global *rootOpts) *cobra.Command {
+	o := &trashListOpts{}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "Show items in the trash",
+		Long:    `Shows removed and overwritten entries in the trash, from the newest to the oldest.`,
+		Aliases: []string{"lst", "ls", "l", "li"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trashListRun(global, o)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format (text, json or yaml), instead of the one set in settings")
+
+	return cmd
+}
+
+type trashListOpts struct {
+	output string
+}
+
+type trashItem struct {
+	ID       string    `json:"id" yaml:"id"`
+	Name     string    `json:"name" yaml:"name"`
+	Registry string    `json:"registry" yaml:"registry"`
+	Time     time.Time `json:"time" yaml:"time"`
+}
+
+func trashListRun(g *rootOpts, o *trashListOpts) error {
+	out, err := g.output(o.output)
+	if err != nil {
+		return err
+	}
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	ls, err := regs.Trash()
+	if err != nil {
+		return err
+	}
+
+	items := make([]trashItem, 0, len(ls))
+	for _, t := range ls {
+		items = append(items, trashItem{ID: t.ID, Name: t.Name, Registry: t.Source.Name, Time: t.Time})
+	}
+	if out != settings.OutputText {
+		return ui.PrintStructured(out, items)
+	}
+
+	if len(items) == 0 {
+		fmt.Println("The trash is empty.")
+		return nil
+	}
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tENTRY\tREGISTRY\tREMOVED")
+	for _, i := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s ago\n", i.ID, i.Name, i.Registry, ui.HumanDuration(now.Sub(i.Time)))
+	}
+	return w.Flush()
+}
+
+// newTrashRestoreCmd generates a new trash restore command
+func newTrashRestoreCmd(global *rootOpts) *cobra.Command {
+	o := &trashRestoreOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "restore [id or entry name] [new name]",
+		Short: "Restore an item from the trash",
+		Long:  `Restores the item with the given ID (or the most recently removed version of the given entry) under its original name or the provided new name.`,
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.item = args[0]
+			if len(args) > 1 {
+				o.name = args[1]
+			}
+			return trashRestoreRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&o.force, "force", "f", false, "force override, if the entry with the restored name already exists in the registry")
+
+	return cmd
+}
+
+type trashRestoreOpts struct {
+	item  string
+	name  string
+	force bool
+}
+
+func trashRestoreRun(ctx context.Context, g *rootOpts, o *trashRestoreOpts) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	item, err := regs.FindTrash(o.item)
+	if err != nil {
+		return err
+	}
+
+	var entry registry.Entry
+	if o.name == "" {
+		entry = registry.Entry{Source: item.Source, Name: item.Name, Path: registry.NameToPath(item.Source.Path, item.Name)}
+	} else if entry, err = regs.Target(o.name); err != nil {
+		return err
+	}
+
+	exist, err := registry.Exist(entry.Source.Path, entry.Name)
+	if err != nil {
+		return err
+	}
+	if exist {
+		name := regs.DisplayName(entry)
+		err = g.confirm(
+			"confirm.overwrite", o.force,
+			fmt.Sprintf("Entry %q already exists in the registry, overwrite it", name),
+			fmt.Sprintf("entry %q already exists in the registry; If overwriting it is intended force with '--force' flag", name),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	hook := hookEnv{fromPath: item.Path, to: entry.QualifiedName(), toPath: entry.Path}
+	if err := g.runHook(ctx, hookPreRestore, hook); err != nil {
+		return err
+	}
+	if err := regs.Restore(item, entry, exist); err != nil {
+		return lockHint(err)
+	}
+
+	fmt.Printf("Entry %q restored from the trash.\n", regs.DisplayName(entry))
+	g.runPostHook(ctx, hookPostRestore, hook)
+	return nil
+}
+
+// newTrashEmptyCmd generates a new trash empty command
+func newTrashEmptyCmd(global *rootOpts) *cobra.Command {
+	o := &trashEmptyOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "empty",
+		Short: "Permanently remove items from the trash",
+		Long:  `Permanently removes all items (or items older than the given age) from the trash of all writable registries.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trashEmptyRun(global, o)
+		},
+	}
+
+	cmd.Flags().DurationVar(&o.olderThan, "older-than", 0, "remove only items removed earlier than the given time ago (e.g. 24h)")
+	cmd.Flags().BoolVarP(&o.yes, "yes", "y", false, "do not ask for confirmation")
+
+	return cmd
+}
+
+type trashEmptyOpts struct {
+	olderThan time.Duration
+	yes       bool
+}
+
+func trashEmptyRun(g *rootOpts, o *trashEmptyOpts) error {
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+
+	if !o.yes {
+		if !ui.Interactive() {
+			return fmt.Errorf("emptying the trash requires confirmation; If that is intended confirm with '--yes' flag")
+		}
+		ok, err := ui.Confirm("Permanently remove items from the trash")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	before := time.Now().Add(-o.olderThan)
+	removed := 0
+	for _, s := range regs.Sources() {
+		if s.ReadOnly {
+			continue
+		}
+		n, err := registry.PurgeTrash(s, before)
+		removed += n
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Removed %d item(s) from the trash.\n", removed)
+	return nil
+}
@@ -0,0 +1,429 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/kubecfg"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/state"
+)
+
+const tuiLong = `Displays a full-screen view of all entries (the current one marked with '●')
+together with details of the highlighted one (contexts, clusters, users, expiry
+and labels), where entries are managed with single keys:
+
+  s  switch to the entry          c  duplicate the entry
+  e  edit the entry               d  delete the entry (move it to the trash)
+  r  rename the entry             D  show differences against the current kubectl config
+  h  check health of the entry (whether clusters respond, certificates are valid and
+     credential plugins are installed)
+
+Press '/' to search entries and 'q' to quit.`
+
+// Keys of the tui actions.
+const (
+	tuiSwitch    = 's'
+	tuiEdit      = 'e'
+	tuiRename    = 'r'
+	tuiDuplicate = 'c'
+	tuiDelete    = 'd'
+	tuiDiff      = 'D'
+	tuiHealth    = 'h'
+)
+
+var tuiActions = []ui.BrowserAction{
+	{Key: tuiSwitch, Label: "switch"},
+	{Key: tuiEdit, Label: "edit"},
+	{Key: tuiRename, Label: "rename"},
+	{Key: tuiDuplicate, Label: "copy"},
+	{Key: tuiDelete, Label: "delete"},
+	{Key: tuiDiff, Label: "diff"},
+	{Key: tuiHealth, Label: "health"},
+}
+
+// newTuiCmd generates a new tui command.
+func newTuiCmd(global *rootOpts) *cobra.Command {
+	o := &tuiOpts{}
+
+	cmd := &cobra.Command{
+		Use:     "tui",
+		Short:   "Browse and manage the registry in a full-screen view",
+		Long:    tuiLong,
+		Aliases: []string{"ui"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return tuiRun(cmd.Context(), global, o)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.editor, "editor", "e", "", "sets the editor used directly, instead of using the one from settings or the ${EDITOR} environment variable")
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "show only entries matching the label selector (e.g. 'env=prod')")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", 3*time.Second, "timeout of connections made by the health check")
+
+	return cmd
+}
+
+type tuiOpts struct {
+	editor   string
+	selector string
+	timeout  time.Duration
+}
+
+func tuiRun(ctx context.Context, g *rootOpts, o *tuiOpts) error {
+	if !ui.Interactive() {
+		return fmt.Errorf("tui requires a terminal")
+	}
+	sel, err := registry.ParseSelector(o.selector)
+	if err != nil {
+		return err
+	}
+	theme, err := g.theme()
+	if err != nil {
+		return err
+	}
+
+	status := ""
+	selected := ""
+	for {
+		g.regs = nil // actions (and other processes) modify registries, so metadata has to be read again
+		regs, err := g.registries()
+		if err != nil {
+			return err
+		}
+		kcCfgPath, err := g.kubectlConfigPath()
+		if err != nil {
+			return err
+		}
+		kcCfgHash, err := registry.Hash(kcCfgPath)
+		if err != nil {
+			return err
+		}
+		ls, cmp, err := regs.ListWithCmp(kcCfgHash, sel)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		cursor := 0
+		annotations := make([]string, 0, len(ls))
+		for i, e := range ls {
+			if e.QualifiedName() == selected {
+				cursor = i
+			}
+			meta, err := regs.Metadata(e)
+			if err != nil {
+				return err
+			}
+			a := ""
+			if cmp[i] {
+				a += ui.Colorize(theme.Current, " ●")
+			}
+			switch {
+			case meta.Expired(now):
+				a += ui.Colorize("red", " (expired)")
+			case meta.Expires != nil:
+				a += fmt.Sprintf(" (%s)", ui.HumanDuration(meta.Expires.Sub(now)))
+			}
+			annotations = append(annotations, a)
+		}
+
+		b := ui.Browser{
+			Title:       fmt.Sprintf("kubeconfig - %d entries", len(ls)),
+			Items:       registry.DisplayNames(ls),
+			Annotations: annotations,
+			Detail:      func(i int) []string { return tuiDetail(regs, ls[i]) },
+			Actions:     tuiActions,
+			Status:      status,
+			Theme:       theme,
+			Cursor:      cursor,
+		}
+		key, err := b.Run()
+		if err != nil || key == 0 {
+			return err
+		}
+
+		entry := ls[b.Cursor]
+		selected = entry.QualifiedName()
+		msg, next, err := tuiAction(ctx, g, regs, o, key, entry, kcCfgPath)
+		if next != nil {
+			selected = next.QualifiedName()
+		}
+		switch {
+		case errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) || errors.Is(err, promptui.ErrAbort):
+			status = "Cancelled."
+		case err != nil:
+			status = ui.Colorize("red", "Error: "+err.Error())
+		default:
+			status = msg
+		}
+	}
+}
+
+// tuiDetail returns lines describing the given entry in the detail pane.
+func tuiDetail(regs *registry.Registries, entry registry.Entry) []string {
+	lines := []string{"registry: " + entry.Source.Name, "path: " + entry.Path}
+	if meta, err := regs.Metadata(entry); err == nil {
+		flags := []string{}
+		if meta.Protected {
+			flags = append(flags, ui.Colorize("red", "protected"))
+		}
+		if meta.Locked {
+			flags = append(flags, ui.Colorize("yellow", "locked"))
+		}
+		if len(flags) > 0 {
+			lines = append(lines, strings.Join(flags, ", "))
+		}
+	}
+	return append(append(lines, ""), ui.EntryPreview(regs, entry)...)
+}
+
+// tuiAction performs the action bound to the given key and returns the status message (and the entry to highlight
+// next, when the action created a new one).
+func tuiAction(ctx context.Context, g *rootOpts, regs *registry.Registries, o *tuiOpts, key rune, entry registry.Entry, kcCfgPath string) (string, *registry.Entry, error) {
+	name := regs.DisplayName(entry)
+	switch key {
+	case tuiSwitch:
+		msg, err := tuiSwitchTo(ctx, g, regs, entry, kcCfgPath)
+		return msg, nil, err
+
+	case tuiEdit:
+		editor, err := g.editor(o.editor)
+		if err != nil {
+			return "", nil, err
+		}
+		changed, err := editEntry(ctx, g, regs, entry, editor)
+		if err != nil || !changed {
+			return fmt.Sprintf("Entry %q not modified.", name), nil, err
+		}
+		return fmt.Sprintf("Entry %q modified.", name), nil, nil
+
+	case tuiRename, tuiDuplicate:
+		if key == tuiRename {
+			if err := regs.CheckWritable(entry); err != nil {
+				return "", nil, lockHint(err)
+			}
+		}
+		def := entry.Name
+		if key == tuiDuplicate {
+			def += "-copy"
+		}
+		newName, err := ui.Input(fmt.Sprintf("New name for %q", name), def)
+		if err != nil {
+			return "", nil, err
+		}
+		if s, _ := regs.SplitName(newName); s == nil {
+			newName = entry.Source.Name + registry.NameSeparator + newName // stay in the same registry
+		}
+		target, err := regs.Target(newName)
+		if err != nil {
+			return "", nil, err
+		}
+		if target.QualifiedName() == entry.QualifiedName() {
+			return "Name not changed.", nil, nil
+		}
+		hook := hookEnv{from: entry.QualifiedName(), fromPath: entry.Path, to: target.QualifiedName(), toPath: target.Path}
+		if key == tuiDuplicate {
+			if err := copyEntry(g, regs, entry, target, false, g.preHook(ctx, hookPreDuplicate, hook)); err != nil {
+				return "", nil, err
+			}
+			g.runPostHook(ctx, hookPostDuplicate, hook)
+			return fmt.Sprintf("Entry %q duplicated as %q.", name, regs.DisplayName(target)), &target, nil
+		}
+		if err := copyEntry(g, regs, entry, target, true, g.preHook(ctx, hookPreRename, hook)); err != nil {
+			return "", nil, err
+		}
+		if err := regs.Remove(entry); err != nil {
+			return "", nil, lockHint(err)
+		}
+		err = state.Update(func(s *state.State) error {
+			s.Rename(entry.QualifiedName(), target.QualifiedName())
+			return nil
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		g.runPostHook(ctx, hookPostRename, hook)
+		return fmt.Sprintf("Entry %q renamed to %q.", name, regs.DisplayName(target)), &target, nil
+
+	case tuiDelete:
+		if err := regs.CheckWritable(entry); err != nil {
+			return "", nil, lockHint(err)
+		}
+		ok, err := ui.Confirm(fmt.Sprintf("Move %q to the trash", name))
+		if err != nil || !ok {
+			return "Cancelled.", nil, err
+		}
+		hook := hookEnv{from: entry.QualifiedName(), fromPath: entry.Path}
+		if err := g.runHook(ctx, hookPreRemove, hook); err != nil {
+			return "", nil, err
+		}
+		if err := regs.Remove(entry); err != nil {
+			return "", nil, lockHint(err)
+		}
+		g.runPostHook(ctx, hookPostRemove, hook)
+		return fmt.Sprintf("Entry %q moved to the trash (restore it with 'kubeconfig trash restore').", name), nil, nil
+
+	case tuiDiff:
+		current, err := os.ReadFile(kcCfgPath)
+		if err != nil {
+			return "", nil, fmt.Errorf("cannot read file %q: %w", kcCfgPath, err)
+		}
+		content, err := os.ReadFile(entry.Path)
+		if err != nil {
+			return "", nil, fmt.Errorf("cannot read file %q: %w", entry.Path, err)
+		}
+		lines := ui.Diff(kcCfgPath, name, string(current), string(content))
+		if len(lines) == 0 {
+			return fmt.Sprintf("Entry %q does not differ from the current kubectl config.", name), nil, nil
+		}
+		return "", nil, ui.Pager(fmt.Sprintf("Differences between the current kubectl config and %q", name), lines)
+
+	case tuiHealth:
+		cfg, err := kubecfg.ReadFile(entry.Path)
+		if err != nil {
+			return "", nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Checking health of %q...\n", name)
+		return "", nil, ui.Pager(fmt.Sprintf("Health of %q", name), entryHealth(ctx, cfg, filepath.Dir(kcCfgPath), o.timeout))
+	}
+	return "", nil, nil
+}
+
+// tuiSwitchTo switches to the given entry, asking for confirmations interactively.
+func tuiSwitchTo(ctx context.Context, g *rootOpts, regs *registry.Registries, entry registry.Entry, kcCfgPath string) (string, error) {
+	kcCfgHash, err := registry.Hash(kcCfgPath)
+	if err != nil {
+		return "", err
+	}
+	current, found, err := regs.Find(kcCfgHash)
+	if err != nil {
+		return "", err
+	}
+	var previous *registry.Entry
+	if found {
+		previous = &current
+	} else {
+		err = g.confirm(
+			"confirm.switch-unknown", false,
+			"The current kubectl config do not exists in the registry, override it",
+			"the current kubectl config do not exists in the registry and switching config files will override it; If that is intended use 'kubeconfig switch --force'",
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := guardProtected(g, regs, entry, false); err != nil {
+		return "", err
+	}
+
+	hook, err := applySwitch(ctx, g, entry, previous, kcCfgPath)
+	if err != nil {
+		return "", err
+	}
+	g.runPostHook(ctx, hookPostSwitch, hook)
+	if err := scheduleRevert(g, regs, entry); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Switched to %q.", regs.DisplayName(entry)), nil
+}
+
+// copyEntry writes content of the entry under the target name, together with its labels and protection (and, when
+// moving, also the lock and expiry). The pre function is passed to writeEntry.
+func copyEntry(g *rootOpts, regs *registry.Registries, entry registry.Entry, target registry.Entry, move bool, pre func() error) error {
+	content, err := registry.Read(entry.Path)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	if err := writeEntry(g, regs, target, content, false, pre); err != nil {
+		return err
+	}
+
+	meta, err := regs.Metadata(entry)
+	if err != nil {
+		return err
+	}
+	m := &registry.EntryMetadata{Labels: meta.Labels, Protected: meta.Protected}
+	if move {
+		m.Locked, m.Expires = meta.Locked, meta.Expires
+	}
+	return regs.SetMetadata(target, m)
+}
+
+// entryHealth checks whether clusters of the given kubectl config respond, whether its client certificates are valid
+// and whether its credential plugins are installed (relative plugin commands are resolved against the given directory).
+func entryHealth(ctx context.Context, cfg *kubecfg.Config, dir string, timeout time.Duration) []string {
+	good := func(format string, a ...interface{}) string {
+		return ui.Colorize("green", "✔ ") + fmt.Sprintf(format, a...)
+	}
+	bad := func(format string, a ...interface{}) string {
+		return ui.Colorize("red", "✘ ") + fmt.Sprintf(format, a...)
+	}
+
+	lines := []string{}
+	if len(cfg.Clusters) == 0 {
+		lines = append(lines, bad("no clusters"))
+	}
+	for _, c := range cfg.Clusters {
+		addr, err := serverAddress(c.Cluster.Server)
+		if err != nil {
+			lines = append(lines, bad("cluster %q: %v", c.Name, err))
+			continue
+		}
+		start := time.Now()
+		d := net.Dialer{Timeout: timeout}
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			lines = append(lines, bad("cluster %q: %s does not respond: %v", c.Name, addr, err))
+			continue
+		}
+		conn.Close()
+		lines = append(lines, good("cluster %q: %s responds (in %s)", c.Name, addr, time.Since(start).Round(time.Millisecond)))
+	}
+
+	now := time.Now()
+	for _, u := range cfg.Users {
+		t, found, err := u.User.CertificateExpiry()
+		switch {
+		case err != nil:
+			lines = append(lines, bad("user %q: cannot parse client certificate: %v", u.Name, err))
+		case found && !t.After(now):
+			lines = append(lines, bad("user %q: client certificate expired: %s", u.Name, ui.ExpirySummary(t, now)))
+		case found:
+			lines = append(lines, good("user %q: client certificate valid until %s", u.Name, ui.ExpirySummary(t, now)))
+		}
+		if u.User.Exec != nil {
+			if path, err := u.User.Exec.LookPath(dir); err != nil {
+				lines = append(lines, bad("user %q: credential plugin %q is not installed", u.Name, u.User.Exec.Command))
+			} else {
+				lines = append(lines, good("user %q: credential plugin %q found at %s", u.Name, u.User.Exec.Command, path))
+			}
+		}
+	}
+	return lines
+}
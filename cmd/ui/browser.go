@@ -0,0 +1,365 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/manifoldco/promptui"
+)
+
+// Browser is a full-screen view of a list of items with a detail pane of the highlighted item. Keys bound to actions
+// end the view and are reported to the caller, which performs the action (possibly prompting on the regular screen) and
+// usually displays the view again.
+type Browser struct {
+	// Title is displayed on top of the screen.
+	Title string
+	// Items are searched and displayed in the list pane.
+	Items []string
+	// Annotations (optional) are displayed next to items, but are not searched.
+	Annotations []string
+	// Detail (optional) returns lines displayed in the detail pane for the item with the given index.
+	Detail func(i int) []string
+	// Actions are keys ending the view, described in the help line.
+	Actions []BrowserAction
+	// Status (optional) is displayed above the help line (e.g. the result of the last action).
+	Status string
+	// Theme describes colors used.
+	Theme Theme
+	// Cursor is the index of the highlighted item (initially and after the view ends).
+	Cursor int
+
+	query   []rune
+	search  bool
+	matches []fuzzyResult
+	pos     int
+	top     int
+	details map[int][]string
+	out     io.Writer
+}
+
+// BrowserAction describes a key bound to an action.
+type BrowserAction struct {
+	Key   rune
+	Label string
+}
+
+// Run displays the view until a key bound to an action is pressed (and returns it) or the view is closed with 'q' (and
+// zero is returned).
+func (b *Browser) Run() (rune, error) {
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		return 0, errors.New("interactive view requires a terminal")
+	}
+	b.out = readline.Stderr
+	b.details = map[int][]string{}
+	b.filter()
+	for i, m := range b.matches {
+		if m.index == b.Cursor {
+			b.pos = i
+		}
+	}
+
+	restore, err := enterFullScreen(b.out)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+
+	buf := make([]byte, 64)
+	for {
+		b.render()
+		n, err := readline.Stdin.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		for in := buf[:n]; len(in) > 0; {
+			key, r, size := parseKey(in)
+			in = in[size:]
+			action, done, err := b.handle(key, r)
+			if err != nil || done {
+				return action, err
+			}
+		}
+	}
+}
+
+func (b *Browser) handle(key pickerKey, r rune) (rune, bool, error) {
+	if b.search {
+		switch key {
+		case keyRune:
+			b.query = append(b.query, r)
+			b.filter()
+		case keyBackspace:
+			if len(b.query) > 0 {
+				b.query = b.query[:len(b.query)-1]
+				b.filter()
+			}
+		case keyEnter, keyEscape, keySearch:
+			b.search = false
+		case keyInterrupt:
+			return 0, true, nil
+		}
+		return 0, false, nil
+	}
+
+	switch key {
+	case keyRune:
+		switch r {
+		case 'q':
+			return 0, true, nil
+		case '/':
+			b.search = true
+			return 0, false, nil
+		case 'j':
+			b.move(1)
+			return 0, false, nil
+		case 'k':
+			b.move(-1)
+			return 0, false, nil
+		}
+		for _, a := range b.Actions {
+			if a.Key == r && len(b.matches) > 0 {
+				b.Cursor = b.matches[b.pos].index
+				return r, true, nil
+			}
+		}
+	case keyEscape:
+		if len(b.query) > 0 {
+			b.query = nil
+			b.filter()
+		}
+	case keySearch:
+		b.search = true
+	case keyUp:
+		b.move(-1)
+	case keyDown:
+		b.move(1)
+	case keyPageUp:
+		b.move(-b.listHeight())
+	case keyPageDown:
+		b.move(b.listHeight())
+	case keyInterrupt, keyEOF:
+		return 0, true, nil
+	}
+	return 0, false, nil
+}
+
+func (b *Browser) filter() {
+	b.matches = fuzzyFilter(string(b.query), b.Items)
+	b.pos, b.top = 0, 0
+}
+
+func (b *Browser) move(delta int) {
+	b.pos += delta
+	if b.pos >= len(b.matches) {
+		b.pos = len(b.matches) - 1
+	}
+	if b.pos < 0 {
+		b.pos = 0
+	}
+}
+
+// listHeight returns the number of rows of the list pane.
+func (b *Browser) listHeight() int {
+	_, height := screenSize()
+	return maxInt(height-4, 1)
+}
+
+func (b *Browser) render() {
+	width, _ := screenSize()
+	rows := b.listHeight()
+	if b.pos < b.top {
+		b.top = b.pos
+	}
+	if b.pos >= b.top+rows {
+		b.top = b.pos - rows + 1
+	}
+
+	left := 0
+	for i, item := range b.Items {
+		w := visibleLen(item)
+		if i < len(b.Annotations) {
+			w += visibleLen(b.Annotations[i])
+		}
+		left = maxInt(left, w+2)
+	}
+	left = minInt(left, width/2)
+	right := maxInt(width-left-3, 0)
+
+	var detail []string
+	if b.Detail != nil && len(b.matches) > 0 {
+		idx := b.matches[b.pos].index
+		d, ok := b.details[idx]
+		if !ok {
+			d = b.Detail(idx)
+			b.details[idx] = d
+		}
+		detail = d
+	}
+
+	lines := []string{}
+	title := colorize(os.Stderr, "bold", b.Title)
+	switch {
+	case b.search:
+		title += "  /" + string(b.query) + "█"
+	case len(b.query) > 0:
+		title += colorize(os.Stderr, "faint", "  filter: "+string(b.query))
+	}
+	lines = append(lines, truncateVisible(title, width))
+	for i := 0; i < rows; i++ {
+		item := ""
+		if j := b.top + i; j < len(b.matches) {
+			m := b.matches[j]
+			annotation := ""
+			if m.index < len(b.Annotations) {
+				annotation = b.Annotations[m.index]
+			}
+			text := truncate(b.Items[m.index], left-2-visibleLen(annotation))
+			if j == b.pos {
+				item = promptui.IconSelect + " " + highlight(text, m.positions, "yellow", b.Theme.Active) + annotation
+			} else {
+				item = "  " + highlight(text, m.positions, "yellow", "") + annotation
+			}
+		} else if i == 0 && len(b.matches) == 0 {
+			item = colorize(os.Stderr, "faint", "  (no matching entries)")
+		}
+		item = truncateVisible(item, left)
+		line := item + strings.Repeat(" ", maxInt(left-visibleLen(item), 0)) + colorize(os.Stderr, "faint", " │ ")
+		if i < len(detail) {
+			line += truncateVisible(detail[i], right)
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, truncateVisible(b.Status, width))
+
+	help := []string{"/ search"}
+	for _, a := range b.Actions {
+		help = append(help, fmt.Sprintf("%c %s", a.Key, a.Label))
+	}
+	help = append(help, "q quit")
+	lines = append(lines, colorize(os.Stderr, "faint", truncate(strings.Join(help, "  "), width)))
+
+	drawScreen(b.out, lines)
+}
+
+// Pager displays the given lines in a full-screen, scrollable view, until closed with 'q' (or escape).
+func Pager(title string, lines []string) error {
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("interactive view requires a terminal")
+	}
+	out := readline.Stderr
+	restore, err := enterFullScreen(out)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	top := 0
+	buf := make([]byte, 64)
+	for {
+		width, height := screenSize()
+		rows := maxInt(height-2, 1)
+		top = maxInt(minInt(top, len(lines)-rows), 0)
+
+		screen := []string{truncateVisible(colorize(os.Stderr, "bold", title), width)}
+		for i := top; i < top+rows; i++ {
+			if i < len(lines) {
+				screen = append(screen, truncateVisible(lines[i], width))
+			} else {
+				screen = append(screen, "")
+			}
+		}
+		screen = append(screen, colorize(os.Stderr, "faint", truncate(fmt.Sprintf("lines %d-%d of %d  ↑/↓ scroll  q close", minInt(top+1, len(lines)), minInt(top+rows, len(lines)), len(lines)), width)))
+		drawScreen(out, screen)
+
+		n, err := readline.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		for in := buf[:n]; len(in) > 0; {
+			key, r, size := parseKey(in)
+			in = in[size:]
+			switch {
+			case key == keyUp || (key == keyRune && r == 'k'):
+				top--
+			case key == keyDown || (key == keyRune && r == 'j'):
+				top++
+			case key == keyPageUp:
+				top -= rows
+			case key == keyPageDown || (key == keyRune && r == ' '):
+				top += rows
+			case key == keyEscape || key == keyInterrupt || key == keyEOF || key == keyEnter || (key == keyRune && r == 'q'):
+				return nil
+			}
+		}
+	}
+}
+
+// enterFullScreen switches the terminal to the raw mode and the alternate screen. The returned function restores the
+// previous state.
+func enterFullScreen(out io.Writer) (func(), error) {
+	state, err := readline.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("cannot set up terminal: %w", err)
+	}
+	fmt.Fprint(out, "\033[?1049h\033[?25l") // alternate screen, hide cursor
+	return func() {
+		fmt.Fprint(out, "\033[?25h\033[?1049l")
+		readline.Restore(int(os.Stdin.Fd()), state) //nolint:errcheck
+	}, nil
+}
+
+func drawScreen(out io.Writer, lines []string) {
+	b := strings.Builder{}
+	b.WriteString("\033[H")
+	for i, l := range lines {
+		b.WriteString(l)
+		b.WriteString("\033[0m\033[K")
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\033[J")
+	fmt.Fprint(out, b.String())
+}
+
+// screenSize returns the size of the terminal (or the common default, when it cannot be obtained).
+func screenSize() (int, int) {
+	width, height, err := readline.GetSize(int(os.Stderr.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// truncateVisible truncates the text to the given number of visible runes, keeping terminal escape sequences intact.
+func truncateVisible(s string, width int) string {
+	if visibleLen(s) <= width {
+		return s
+	}
+	b := strings.Builder{}
+	n := 0
+	for len(s) > 0 && n < width-1 {
+		if loc := escapeSequenceRegexp.FindStringIndex(s); loc != nil && loc[0] == 0 {
+			b.WriteString(s[:loc[1]])
+			s = s[loc[1]:]
+			continue
+		}
+		r := []rune(s)[0]
+		b.WriteRune(r)
+		s = s[len(string(r)):]
+		n++
+	}
+	b.WriteString("…\033[0m")
+	return b.String()
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
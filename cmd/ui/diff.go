@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines displayed around changes.
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// Diff returns a unified diff of the given texts (empty, when they are equal), with removed lines colored red and added
+// lines colored green.
+func Diff(fromName string, toName string, from string, to string) []string {
+	if from == to {
+		return nil
+	}
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	lines := []string{Colorize("bold", "--- "+fromName), Colorize("bold", "+++ "+toName)}
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// hunk spans changes separated by at most 2*diffContext unchanged lines (plus context around them)
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		last := i
+		for j := i + 1; j < len(ops) && j-last <= 2*diffContext+1; j++ {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+		}
+		end := last + 1 + diffContext
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		fromLine, toLine := hunkStart(ops, start)
+		fromCount, toCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				fromCount++
+			}
+			if op.kind != '-' {
+				toCount++
+			}
+		}
+		lines = append(lines, Colorize("cyan", fmt.Sprintf("@@ -%d,%d +%d,%d @@", fromLine, fromCount, toLine, toCount)))
+		for _, op := range ops[start:end] {
+			switch op.kind {
+			case '-':
+				lines = append(lines, Colorize("red", "-"+op.text))
+			case '+':
+				lines = append(lines, Colorize("green", "+"+op.text))
+			default:
+				lines = append(lines, " "+op.text)
+			}
+		}
+		i = end
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// hunkStart returns 1-based line numbers in both texts of the operation with the given index.
+func hunkStart(ops []diffOp, idx int) (int, int) {
+	from, to := 1, 1
+	for _, op := range ops[:idx] {
+		if op.kind != '+' {
+			from++
+		}
+		if op.kind != '-' {
+			to++
+		}
+	}
+	return from, to
+}
+
+// diffLines computes the line diff based on the longest common subsequence.
+func diffLines(a []string, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fuzzy matching scores.
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusConsecutive = 8
+	fuzzyBonusBoundary    = 8
+	fuzzyBonusPrefix      = 4
+	fuzzyPenaltyGap       = 1
+)
+
+// FuzzyMatch performs case-insensitive subsequence matching of the pattern against the text. It reports whether the
+// text matches, the match score (higher is better) and positions (rune indexes) of matched characters in the text.
+// Consecutive matches and matches at word boundaries (after '/', '-', '_', '.', ':' or space) are preferred.
+func FuzzyMatch(pattern string, text string) (bool, int, []int) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+	if len(p) == 0 {
+		return true, 0, nil
+	}
+
+	// forward pass finds the end of the first match
+	pi, end := 0, -1
+	for ti := 0; ti < len(t) && pi < len(p); ti++ {
+		if t[ti] == p[pi] {
+			pi++
+			if pi == len(p) {
+				end = ti
+			}
+		}
+	}
+	if end < 0 {
+		return false, 0, nil
+	}
+
+	// backward pass finds the shortest match window ending there
+	pi, start := len(p)-1, end
+	for ti := end; ti >= 0; ti-- {
+		if t[ti] == p[pi] {
+			pi--
+			if pi < 0 {
+				start = ti
+				break
+			}
+		}
+	}
+
+	positions := make([]int, 0, len(p))
+	score := 0
+	pi = 0
+	for ti := start; ti <= end && pi < len(p); ti++ {
+		if t[ti] != p[pi] {
+			score -= fuzzyPenaltyGap
+			continue
+		}
+		score += fuzzyScoreMatch
+		if n := len(positions); n > 0 && positions[n-1] == ti-1 {
+			score += fuzzyBonusConsecutive
+		}
+		if ti == 0 || isFuzzyBoundary(t[ti-1]) {
+			score += fuzzyBonusBoundary
+		}
+		positions = append(positions, ti)
+		pi++
+	}
+	if start == 0 {
+		score += fuzzyBonusPrefix
+	}
+	return true, score, positions
+}
+
+func isFuzzyBoundary(r rune) bool {
+	return r == '/' || r == '-' || r == '_' || r == '.' || r == ':' || unicode.IsSpace(r)
+}
+
+// fuzzyResult is a single item matching the fuzzy search.
+type fuzzyResult struct {
+	index     int
+	score     int
+	positions []int
+}
+
+// fuzzyFilter returns items matching the pattern, sorted by the match score (items with equal scores keep their order).
+func fuzzyFilter(pattern string, items []string) []fuzzyResult {
+	res := make([]fuzzyResult, 0, len(items))
+	for i, item := range items {
+		if ok, score, positions := FuzzyMatch(pattern, item); ok {
+			res = append(res, fuzzyResult{index: i, score: score, positions: positions})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].score > res[j].score })
+	return res
+}
+
+// highlight renders the text in the base color (if known), with characters at given (rune) positions emphasized in
+// the highlight color.
+func highlight(text string, positions []int, color string, base string) string {
+	baseCode, ok := colorCodes[base]
+	if !ok {
+		baseCode = "39"
+	}
+	code, ok := colorCodes[color]
+	if !ok {
+		code = baseCode
+	}
+
+	b := strings.Builder{}
+	b.WriteString("\033[" + baseCode + "m")
+	next := 0
+	for i, r := range []rune(text) {
+		if next < len(positions) && positions[next] == i {
+			b.WriteString("\033[1;4;" + code + "m" + string(r) + "\033[22;24;" + baseCode + "m")
+			next++
+			continue
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString("\033[0m")
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		text      string
+		match     bool
+		positions []int
+	}{
+		{name: "empty pattern", pattern: "", text: "aws/prod", match: true},
+		{name: "subsequence", pattern: "apd", text: "aws/prod", match: true, positions: []int{0, 4, 7}},
+		{name: "consecutive", pattern: "prod", text: "aws/prod", match: true, positions: []int{4, 5, 6, 7}},
+		{name: "case-insensitive", pattern: "AWS", text: "Aws/Prod", match: true, positions: []int{0, 1, 2}},
+		{name: "shortest window", pattern: "ab", text: "a-xab", match: true, positions: []int{3, 4}},
+		{name: "wrong order", pattern: "dp", text: "aws/prod", match: false},
+		{name: "missing character", pattern: "prox", text: "aws/prod", match: false},
+		{name: "longer than text", pattern: "prod-1", text: "prod", match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, _, positions := FuzzyMatch(tt.pattern, tt.text)
+			if match != tt.match {
+				t.Fatalf("FuzzyMatch(%q, %q) match = %v, want %v", tt.pattern, tt.text, match, tt.match)
+			}
+			if !reflect.DeepEqual(positions, tt.positions) {
+				t.Errorf("FuzzyMatch(%q, %q) positions = %v, want %v", tt.pattern, tt.text, positions, tt.positions)
+			}
+		})
+	}
+}
+
+func TestFuzzyFilterRanking(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		items   []string
+		want    []string
+	}{
+		{
+			name:    "word starts before scattered",
+			pattern: "sp",
+			items:   []string{"aws/shop", "gcp/staging-prod", "aws/sp-test"},
+			want:    []string{"aws/sp-test", "gcp/staging-prod", "aws/shop"},
+		},
+		{
+			name:    "prefix before inner match",
+			pattern: "dev",
+			items:   []string{"team/dev", "dev"},
+			want:    []string{"dev", "team/dev"},
+		},
+		{
+			name:    "case does not matter",
+			pattern: "PROD",
+			items:   []string{"staging", "Prod", "aws/prod"},
+			want:    []string{"Prod", "aws/prod"},
+		},
+		{
+			name:    "equal scores keep order",
+			pattern: "a",
+			items:   []string{"x/a", "y/a"},
+			want:    []string{"x/a", "y/a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, r := range fuzzyFilter(tt.pattern, tt.items) {
+				got = append(got, tt.items[r.index])
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("fuzzyFilter(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
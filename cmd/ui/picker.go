@@ -0,0 +1,430 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chzyer/readline"
+	"github.com/manifoldco/promptui"
+)
+
+// Picker is an interactive, fuzzy searchable list of items with a preview pane of the highlighted item.
+type Picker struct {
+	// Label is displayed on top of the list.
+	Label string
+	// Items are searched and displayed.
+	Items []string
+	// Annotations (optional) are displayed next to items, but are not searched.
+	Annotations []string
+	// Preview (optional) returns lines displayed in the preview pane for the item with the given index.
+	Preview func(i int) []string
+	// Theme describes colors used.
+	Theme Theme
+	// Size is the number of items displayed at once.
+	Size int
+	// PreviewSize is the maximal number of lines in the preview pane.
+	PreviewSize int
+	// Folders enables navigation of items with '/' separated names as a hierarchy of folders.
+	Folders bool
+
+	dir      string
+	rows     []pickerRow
+	query    []rune
+	search   bool
+	matches  []fuzzyResult
+	cursor   int
+	top      int
+	drawn    int
+	previews map[int][]string
+	out      io.Writer
+}
+
+// pickerRow is a single row of the picker - an item or (in the folder mode) a folder.
+type pickerRow struct {
+	label  string
+	index  int    // index of the item (-1 for folders)
+	folder string // folder name (for folders)
+	count  int    // number of items in the folder (for folders)
+}
+
+// parentFolder is the name of the row leading to the parent folder.
+const parentFolder = ".."
+
+// Picker keys.
+type pickerKey int
+
+const (
+	keyNone pickerKey = iota
+	keyRune
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyEnter
+	keyBackspace
+	keySearch
+	keyInterrupt
+	keyEOF
+	keyEscape
+)
+
+// Run displays the picker and returns the index of the selected item.
+func (p *Picker) Run() (int, error) {
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		return 0, errors.New("interactive prompt requires a terminal")
+	}
+	if p.Size <= 0 {
+		p.Size = 20
+	}
+	if p.PreviewSize <= 0 {
+		p.PreviewSize = 12
+	}
+	p.out = readline.Stderr
+	p.search = true
+	p.previews = map[int][]string{}
+	p.filter()
+
+	state, err := readline.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return 0, fmt.Errorf("cannot set up terminal: %w", err)
+	}
+	defer readline.Restore(int(os.Stdin.Fd()), state) //nolint:errcheck
+
+	fmt.Fprint(p.out, "\033[?25l") // hide cursor
+	defer fmt.Fprint(p.out, "\033[?25h")
+
+	buf := make([]byte, 64)
+	p.render()
+	for {
+		n, err := readline.Stdin.Read(buf)
+		if err != nil {
+			p.clear()
+			return 0, err
+		}
+		for in := buf[:n]; len(in) > 0; {
+			key, r, size := parseKey(in)
+			in = in[size:]
+			idx, done, err := p.handle(key, r)
+			if err != nil {
+				p.clear()
+				return 0, err
+			}
+			if done {
+				p.clear()
+				fmt.Fprintf(p.out, "%s %s\n", promptui.IconGood, colorize(os.Stderr, "faint", p.Items[idx]))
+				return idx, nil
+			}
+		}
+		p.render()
+	}
+}
+
+func (p *Picker) handle(key pickerKey, r rune) (int, bool, error) {
+	switch key {
+	case keyRune:
+		if p.search {
+			p.query = append(p.query, r)
+			p.filter()
+		}
+	case keyBackspace:
+		if p.search && len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+			p.filter()
+		} else if p.dir != "" {
+			p.enter(parentFolder)
+		}
+	case keySearch:
+		p.search = !p.search
+		if !p.search {
+			p.query = nil
+			p.filter()
+		}
+	case keyUp:
+		p.move(-1)
+	case keyDown:
+		p.move(1)
+	case keyPageUp:
+		p.move(-p.Size)
+	case keyPageDown:
+		p.move(p.Size)
+	case keyEnter:
+		if len(p.matches) > 0 {
+			row := p.rows[p.matches[p.cursor].index]
+			if row.index < 0 {
+				p.enter(row.folder)
+				return 0, false, nil
+			}
+			return row.index, true, nil
+		}
+	case keyInterrupt, keyEscape:
+		return 0, false, promptui.ErrInterrupt
+	case keyEOF:
+		if len(p.query) == 0 {
+			return 0, false, promptui.ErrEOF
+		}
+	}
+	return 0, false, nil
+}
+
+// enter changes the current folder to the given subfolder (or the parent folder).
+func (p *Picker) enter(folder string) {
+	if folder == parentFolder {
+		d := strings.TrimSuffix(p.dir, "/")
+		if i := strings.LastIndex(d, "/"); i >= 0 {
+			p.dir = d[:i+1]
+		} else {
+			p.dir = ""
+		}
+	} else {
+		p.dir += folder + "/"
+	}
+	p.query = nil
+	p.filter()
+}
+
+func (p *Picker) filter() {
+	p.rows = p.buildRows()
+	labels := make([]string, 0, len(p.rows))
+	for _, r := range p.rows {
+		labels = append(labels, r.label)
+	}
+	p.matches = fuzzyFilter(string(p.query), labels)
+	p.cursor, p.top = 0, 0
+	if p.dir != "" && len(p.query) == 0 && len(p.matches) > 1 {
+		p.cursor = 1 // skip the parent folder row
+	}
+}
+
+// buildRows returns rows of the current folder (or all items, when the folder mode is not enabled).
+func (p *Picker) buildRows() []pickerRow {
+	rows := []pickerRow{}
+	if !p.Folders {
+		for i, item := range p.Items {
+			rows = append(rows, pickerRow{label: item, index: i})
+		}
+		return rows
+	}
+
+	if p.dir != "" {
+		rows = append(rows, pickerRow{label: parentFolder + "/", index: -1, folder: parentFolder})
+	}
+	folders := map[string]int{}
+	for i, item := range p.Items {
+		if !strings.HasPrefix(item, p.dir) {
+			continue
+		}
+		rest := item[len(p.dir):]
+		if j := strings.Index(rest, "/"); j >= 0 {
+			name := rest[:j]
+			if k, ok := folders[name]; ok {
+				rows[k].count++
+				continue
+			}
+			folders[name] = len(rows)
+			rows = append(rows, pickerRow{label: name + "/", index: -1, folder: name, count: 1})
+			continue
+		}
+		rows = append(rows, pickerRow{label: rest, index: i})
+	}
+	return rows
+}
+
+// folderPreview returns lines describing the content of the given folder.
+func (p *Picker) folderPreview(folder string) []string {
+	prefix := p.dir + folder + "/"
+	if folder == parentFolder {
+		return []string{"go back to the parent folder"}
+	}
+	lines := []string{}
+	for _, item := range p.Items {
+		if strings.HasPrefix(item, prefix) {
+			lines = append(lines, item)
+		}
+	}
+	return lines
+}
+
+func (p *Picker) move(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+	if p.cursor < p.top {
+		p.top = p.cursor
+	}
+	if p.cursor >= p.top+p.Size {
+		p.top = p.cursor - p.Size + 1
+	}
+}
+
+func (p *Picker) render() {
+	width := readline.GetScreenWidth()
+	if width <= 0 {
+		width = 80
+	}
+
+	lines := []string{}
+	query := string(p.query)
+	if p.search {
+		query += "█"
+	}
+	label := p.Label
+	if p.Folders {
+		label += " [/" + p.dir + "]"
+	}
+	lines = append(lines, fmt.Sprintf("%s %s: %s", colorize(os.Stderr, "bold", promptui.IconInitial), colorize(os.Stderr, "bold", label), query))
+
+	if len(p.matches) == 0 {
+		lines = append(lines, colorize(os.Stderr, "faint", "  (no matching entries)"))
+	}
+	for i := p.top; i < len(p.matches) && i < p.top+p.Size; i++ {
+		m := p.matches[i]
+		row := p.rows[m.index]
+		annotation := ""
+		switch {
+		case row.index < 0 && row.count > 0:
+			annotation = colorize(os.Stderr, "faint", fmt.Sprintf(" (%d)", row.count))
+		case row.index >= 0 && row.index < len(p.Annotations):
+			annotation = p.Annotations[row.index]
+		}
+		item := truncate(row.label, width-4-visibleLen(annotation))
+		prefix := "  "
+		line := ""
+		if i == p.cursor {
+			prefix = promptui.IconSelect + " "
+			line = highlight(item, m.positions, "yellow", p.Theme.Active)
+		} else if row.index < 0 {
+			line = highlight(item, m.positions, "yellow", "blue")
+		} else {
+			line = highlight(item, m.positions, "yellow", "")
+		}
+		lines = append(lines, prefix+line+annotation)
+	}
+
+	if p.Preview != nil && len(p.matches) > 0 {
+		row := p.rows[p.matches[p.cursor].index]
+		preview, ok := p.previews[row.index]
+		if row.index < 0 {
+			preview = p.folderPreview(row.folder)
+		} else if !ok {
+			preview = p.Preview(row.index)
+			p.previews[row.index] = preview
+		}
+		lines = append(lines, colorize(os.Stderr, "faint", "  "+strings.Repeat("─", minInt(width-4, 40))))
+		for i, l := range preview {
+			if i >= p.PreviewSize {
+				lines = append(lines, colorize(os.Stderr, "faint", "  ..."))
+				break
+			}
+			lines = append(lines, "  "+truncate(l, width-4))
+		}
+	}
+
+	b := strings.Builder{}
+	p.writeClear(&b)
+	for _, l := range lines {
+		b.WriteString(l)
+		b.WriteString("\033[K\n")
+	}
+	p.drawn = len(lines)
+	fmt.Fprint(p.out, b.String())
+}
+
+func (p *Picker) clear() {
+	b := strings.Builder{}
+	p.writeClear(&b)
+	p.drawn = 0
+	fmt.Fprint(p.out, b.String())
+}
+
+func (p *Picker) writeClear(b *strings.Builder) {
+	if p.drawn > 0 {
+		fmt.Fprintf(b, "\033[%dA", p.drawn)
+	}
+	b.WriteString("\r\033[J")
+}
+
+// parseKey parses a single key press from the beginning of the input. It returns the key, the typed rune (for keyRune)
+// and the number of consumed bytes.
+func parseKey(in []byte) (pickerKey, rune, int) {
+	switch in[0] {
+	case 3: // ^C
+		return keyInterrupt, 0, 1
+	case 4: // ^D
+		return keyEOF, 0, 1
+	case '\r', '\n':
+		return keyEnter, 0, 1
+	case 127, 8: // backspace
+		return keyBackspace, 0, 1
+	case readline.CharCtrlW:
+		return keySearch, 0, 1
+	case readline.CharPrev:
+		return keyUp, 0, 1
+	case readline.CharNext:
+		return keyDown, 0, 1
+	case readline.CharBackward:
+		return keyPageUp, 0, 1
+	case readline.CharForward:
+		return keyPageDown, 0, 1
+	case 27: // escape sequences
+		if len(in) == 1 {
+			return keyEscape, 0, 1
+		}
+		if len(in) >= 3 && (in[1] == '[' || in[1] == 'O') {
+			switch in[2] {
+			case 'A':
+				return keyUp, 0, 3
+			case 'B':
+				return keyDown, 0, 3
+			case 'C':
+				return keyPageDown, 0, 3
+			case 'D':
+				return keyPageUp, 0, 3
+			}
+			// skip unknown sequence (e.g. '\033[3~')
+			i := 2
+			for i < len(in) && (in[i] < 0x40 || in[i] > 0x7e) {
+				i++
+			}
+			return keyNone, 0, minInt(i+1, len(in))
+		}
+		return keyEscape, 0, 1
+	}
+
+	r, size := utf8.DecodeRune(in)
+	if r == utf8.RuneError || !unicode.IsPrint(r) {
+		return keyNone, 0, size
+	}
+	return keyRune, r, size
+}
+
+var escapeSequenceRegexp = regexp.MustCompile("\033\\[[0-9;?]*[A-Za-z]")
+
+// visibleLen returns the number of runes of the text, excluding terminal escape sequences.
+func visibleLen(s string) int {
+	return utf8.RuneCountInString(escapeSequenceRegexp.ReplaceAllString(s, ""))
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width <= 1 || len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daishe/kubeconfig/kubecfg"
+	"github.com/daishe/kubeconfig/registry"
+)
+
+// EntryPreview returns a short, human readable summary of the given registry entry: its contexts, cluster servers,
+// namespaces, users and certificate expiry. Secrets are never included.
+func EntryPreview(regs *registry.Registries, entry registry.Entry) []string {
+	lines := []string{}
+	if meta, err := regs.Metadata(entry); err == nil {
+		if len(meta.Labels) > 0 {
+			lines = append(lines, "labels: "+strings.Join(registry.SortedLabels(meta.Labels), ","))
+		}
+		if meta.Expires != nil {
+			lines = append(lines, "entry expiry: "+ExpirySummary(*meta.Expires, time.Now()))
+		}
+	}
+
+	cfg, err := kubecfg.ReadFile(entry.Path)
+	if err != nil {
+		return append(lines, fmt.Sprintf("cannot parse: %v", err))
+	}
+	return append(lines, ConfigSummary(cfg, time.Now())...)
+}
+
+// ConfigSummary returns a short, human readable summary of the given kubectl config (without secrets).
+func ConfigSummary(cfg *kubecfg.Config, now time.Time) []string {
+	lines := []string{}
+	if cfg.CurrentContext != "" {
+		lines = append(lines, "current context: "+cfg.CurrentContext)
+	}
+	if len(cfg.Contexts) == 0 {
+		lines = append(lines, "no contexts")
+	}
+	for _, c := range cfg.Contexts {
+		marker := "  "
+		if c.Name == cfg.CurrentContext {
+			marker = "* "
+		}
+		server := "?"
+		if cl, ok := cfg.Cluster(c.Context.Cluster); ok {
+			server = cl.Cluster.Server
+		}
+		ns := c.Context.Namespace
+		if ns == "" {
+			ns = "default"
+		}
+		lines = append(lines,
+			fmt.Sprintf("%s%s (namespace %s)", marker, c.Name, ns),
+			fmt.Sprintf("    server: %s", server),
+			fmt.Sprintf("    user: %s", userSummary(cfg, c.Context.User)),
+		)
+	}
+	if t, ok := cfg.Expiry(); ok {
+		lines = append(lines, "certificate expiry: "+ExpirySummary(t, now))
+	}
+	return lines
+}
+
+func userSummary(cfg *kubecfg.Config, name string) string {
+	u, ok := cfg.User(name)
+	if !ok {
+		return name + " (missing)"
+	}
+	switch {
+	case u.User.Exec != nil:
+		return fmt.Sprintf("%s (exec: %s)", name, u.User.Exec.Command)
+	case u.User.ClientCertificateData != "" || u.User.ClientCertificate != "":
+		return name + " (client certificate)"
+	case u.User.Token != "" || u.User.TokenFile != "":
+		return name + " (token: <redacted>)"
+	case u.User.Username != "":
+		return name + " (basic auth: <redacted>)"
+	}
+	return name
+}
+
+// ExpirySummary describes the given expiration time relative to now.
+func ExpirySummary(t time.Time, now time.Time) string {
+	d := t.Sub(now)
+	if d < 0 {
+		return fmt.Sprintf("%s (expired %s ago)", t.Local().Format("2006-01-02 15:04"), HumanDuration(-d))
+	}
+	return fmt.Sprintf("%s (in %s)", t.Local().Format("2006-01-02 15:04"), HumanDuration(d))
+}
+
+// HumanDuration formats the duration in a short, rounded, human readable form (e.g. '3d', '5h', '12m').
+func HumanDuration(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case d >= 2*time.Minute:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	}
+	return fmt.Sprintf("%ds", int(d/time.Second))
+}
@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"os"
+
+	"github.com/chzyer/readline"
+)
+
+// Theme describes colors used by the interactive prompts.
+type Theme struct {
+	Active  string
+	Current string
+}
+
+var colorCodes = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+	"bold":    "1",
+	"faint":   "2",
+}
+
+// Colorize wraps the given text in terminal escape sequences setting the given color. Text is left unchanged, when the
+// color is unknown or the standard output is not a terminal.
+func Colorize(color string, text string) string {
+	return colorize(os.Stdout, color, text)
+}
+
+func colorizeStderr(color string, text string) string {
+	return colorize(os.Stderr, color, text)
+}
+
+func colorize(f *os.File, color string, text string) string {
+	code, ok := colorCodes[color]
+	if !ok || !readline.IsTerminal(int(f.Fd())) {
+		return text
+	}
+	return "\033[" + code + "m" + text + "\033[0m"
+}
@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+type treeNode struct {
+	name       string
+	annotation string
+	leaf       bool
+	count      int
+	children   []*treeNode
+	index      map[string]*treeNode
+}
+
+func (n *treeNode) folder(name string) *treeNode {
+	if c, ok := n.index[name]; ok && !c.leaf {
+		return c
+	}
+	c := &treeNode{name: name, index: map[string]*treeNode{}}
+	n.index[name] = c
+	n.children = append(n.children, c)
+	return c
+}
+
+// Tree renders '/' separated names as a tree of folders (with numbers of entries they contain). Annotations are
+// appended to corresponding names. Children are kept in the order of the first appearance.
+func Tree(names []string, annotations []string) []string {
+	root := &treeNode{index: map[string]*treeNode{}}
+	for i, name := range names {
+		segments := strings.Split(name, "/")
+		n := root
+		n.count++
+		for _, s := range segments[:len(segments)-1] {
+			n = n.folder(s)
+			n.count++
+		}
+		leaf := &treeNode{name: segments[len(segments)-1], leaf: true}
+		if i < len(annotations) {
+			leaf.annotation = annotations[i]
+		}
+		n.children = append(n.children, leaf)
+	}
+
+	lines := []string{}
+	for _, c := range root.children {
+		lines = c.render(lines, "", "")
+	}
+	return lines
+}
+
+func (n *treeNode) render(lines []string, prefix string, childPrefix string) []string {
+	if n.leaf {
+		return append(lines, prefix+n.name+n.annotation)
+	}
+	lines = append(lines, fmt.Sprintf("%s%s/ (%d)", prefix, Colorize("blue", n.name), n.count))
+	for i, c := range n.children {
+		if i == len(n.children)-1 {
+			lines = c.render(lines, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			lines = c.render(lines, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+	return lines
+}
@@ -1,12 +1,15 @@
 package ui
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/chzyer/readline"
 	"github.com/manifoldco/promptui"
+	"gopkg.in/yaml.v3"
 
 	"github.com/daishe/kubeconfig/registry"
 )
@@ -19,21 +22,50 @@ func DisplayAndExitOnError(err error) {
 	}
 }
 
-// ListToNames converts the provided list of files in registry and the given comparison results to list of names.
-func ListToNames(reg string, files []string) []string {
-	names := make([]string, 0, len(files))
-	for _, f := range files {
-		names = append(names, registry.PathToName(reg, f))
+// AnnotateNamesWithRegistry annotates list of names with names of registries the entries come from. Names are padded,
+// so that annotations are aligned.
+func AnnotateNamesWithRegistry(names []string, entries []registry.Entry) []string {
+	width := 0
+	for _, n := range names {
+		if len(n) > width {
+			width = len(n)
+		}
+	}
+	anno := make([]string, 0, len(names))
+	for i := range names {
+		anno = append(anno, fmt.Sprintf("%-*s   [%s]", width, names[i], entries[i].Source.Name))
 	}
-	return names
+	return anno
+}
+
+// AnnotateNamesWithLabels annotates list of names with labels of the entries. Names are padded, so that annotations
+// are aligned.
+func AnnotateNamesWithLabels(names []string, labels [][]string) []string {
+	width := 0
+	for _, n := range names {
+		if len(n) > width {
+			width = len(n)
+		}
+	}
+	anno := make([]string, 0, len(names))
+	for i := range names {
+		anno = append(anno, strings.TrimRight(fmt.Sprintf("%-*s   %s", width, names[i], strings.Join(labels[i], ",")), " "))
+	}
+	return anno
 }
 
 // AnnotateNamesWithCurrent annotates list of names with information whether the given entry is the current one.
 func AnnotateNamesWithCurrent(names []string, cmp []bool) []string {
+	return AnnotateNamesWithCurrentColored(names, cmp, "")
+}
+
+// AnnotateNamesWithCurrentColored annotates list of names with information whether the given entry is the current one,
+// using the given color for the annotation.
+func AnnotateNamesWithCurrentColored(names []string, cmp []bool, color string) []string {
 	anno := make([]string, 0, len(names))
 	for i := 0; i < len(names); i++ {
 		if cmp[i] {
-			anno = append(anno, names[i]+"   <---- current -----")
+			anno = append(anno, names[i]+Colorize(color, "   <---- current -----"))
 		} else {
 			anno = append(anno, names[i])
 		}
@@ -41,36 +73,122 @@ func AnnotateNamesWithCurrent(names []string, cmp []bool) []string {
 	return anno
 }
 
-// SelectPrompt will display the select prompt with the list of entries in the registry.
-func SelectPrompt(reg string, msg string, hash []byte) (string, error) {
-	ls, cmp, err := registry.ListWithCmp(reg, hash)
-	if err != nil {
-		return "", err
-	}
-	names := ListToNames(reg, ls)
-
-	component := promptui.Select{
-		Label:             msg,
-		Items:             AnnotateNamesWithCurrent(names, cmp),
-		Size:              20,
-		HideHelp:          true,
-		StartInSearchMode: true,
-		Searcher: func(input string, i int) bool {
-			return strings.Contains(names[i], input)
-		},
-		Keys: &promptui.SelectKeys{
-			Prev:     promptui.Key{Code: readline.CharPrev, Display: "↑"},
-			Next:     promptui.Key{Code: readline.CharNext, Display: "↓"},
-			PageUp:   promptui.Key{Code: readline.CharBackward, Display: "←"},
-			PageDown: promptui.Key{Code: readline.CharForward, Display: "→"},
-			Search:   promptui.Key{Code: readline.CharCtrlW, Display: "^W"},
-		},
-	}
-
-	idx, _, err := component.Run()
+// SelectPrompt will display the fuzzy searchable select prompt with the given list of registry entries (and
+// information which of them is the current one), together with a preview of the highlighted entry.
+// In the folder mode, names are navigated as a hierarchy of folders.
+func SelectPrompt(regs *registry.Registries, msg string, ls []registry.Entry, cmp []bool, theme Theme, folders bool) (registry.Entry, error) {
+	if len(ls) == 0 {
+		return registry.Entry{}, fmt.Errorf("no matching entries in the registry")
+	}
+
+	annotations := make([]string, len(ls))
+	for i := range ls {
+		if cmp[i] {
+			annotations[i] = colorizeStderr(theme.Current, "   <---- current -----")
+		}
+	}
+
+	component := Picker{
+		Label:       msg,
+		Items:       registry.DisplayNames(ls),
+		Annotations: annotations,
+		Preview:     func(i int) []string { return EntryPreview(regs, ls[i]) },
+		Theme:       theme,
+		Size:        20,
+		Folders:     folders,
+	}
+
+	idx, err := component.Run()
 	if err != nil {
-		return "", err
+		return registry.Entry{}, err
 	}
 
 	return ls[idx], nil
 }
+
+// Confirm will display the yes/no confirmation prompt with the given question.
+func Confirm(question string) (bool, error) {
+	component := promptui.Prompt{
+		Label:     question,
+		IsConfirm: true,
+	}
+
+	if _, err := component.Run(); err != nil {
+		if err == promptui.ErrAbort {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Interactive reports whether both standard input and output are connected to a terminal.
+func Interactive() bool {
+	return readline.IsTerminal(int(os.Stdin.Fd())) && readline.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// PrintStructured prints the given value to stdout in the provided format (json or yaml).
+func PrintStructured(format string, v interface{}) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// ConfirmByTyping will display the prompt requiring the user to type the expected text to confirm.
+func ConfirmByTyping(question string, expected string) (bool, error) {
+	component := promptui.Prompt{
+		Label: fmt.Sprintf("%s (type %q to confirm)", question, expected),
+	}
+
+	input, err := component.Run()
+	if err != nil {
+		if err == promptui.ErrInterrupt || err == promptui.ErrEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.TrimSpace(input) == expected, nil
+}
+
+// Input will display the prompt for a single line of text, prefilled with the given default value.
+func Input(label string, def string) (string, error) {
+	component := promptui.Prompt{
+		Label:     label,
+		Default:   def,
+		AllowEdit: true,
+	}
+	return component.Run()
+}
+
+// Password will display the prompt for a secret (e.g. a passphrase) with masked input. The prompt is displayed on
+// stderr, so that stdout can carry data.
+func Password(label string) (string, error) {
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("interactive prompt requires a terminal")
+	}
+	component := promptui.Prompt{
+		Label:  label,
+		Mask:   '*',
+		Stdout: os.Stderr,
+	}
+	return component.Run()
+}
+
+// Banner prints the given text to stderr as a prominent, framed banner in the provided color.
+func Banner(color string, text string) {
+	line := strings.Repeat("=", len(text)+8)
+	fmt.Fprintln(os.Stderr, colorizeStderr(color, line))
+	fmt.Fprintln(os.Stderr, colorizeStderr(color, "||  "+text+"  ||"))
+	fmt.Fprintln(os.Stderr, colorizeStderr(color, line))
+}
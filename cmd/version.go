@@ -0,0 +1,140 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
+)
+
+// Build metadata (set by the release workflow).
+var (
+	buildVersion = "development"
+	buildCommit  = "?"
+)
+
+// SetBuildInfo sets the version and the commit the application was built from.
+func SetBuildInfo(version string, commit string) {
+	buildVersion, buildCommit = version, commit
+}
+
+// newVersionCmd generates a new version command.
+func newVersionCmd(global *rootOpts) *cobra.Command {
+	o := &versionOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show version and build information",
+		Long:  "Shows the version of kubeconfig together with build information (Go version, platform, versions of modules and supported formats).",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return versionRun(global, o)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format (text, json or yaml), instead of the one set in settings")
+
+	return cmd
+}
+
+type versionOpts struct {
+	output string
+}
+
+type versionItem struct {
+	Version        string       `json:"version" yaml:"version"`
+	Commit         string       `json:"commit" yaml:"commit"`
+	GoVersion      string       `json:"goVersion" yaml:"goVersion"`
+	OS             string       `json:"os" yaml:"os"`
+	Arch           string       `json:"arch" yaml:"arch"`
+	RegistryFormat int          `json:"registryFormat" yaml:"registryFormat"`
+	BundleFormat   int          `json:"bundleFormat" yaml:"bundleFormat"`
+	Modules        []moduleItem `json:"modules,omitempty" yaml:"modules,omitempty"`
+}
+
+type moduleItem struct {
+	Path    string `json:"path" yaml:"path"`
+	Version string `json:"version" yaml:"version"`
+	Replace string `json:"replace,omitempty" yaml:"replace,omitempty"`
+}
+
+func versionRun(g *rootOpts, o *versionOpts) error {
+	out, err := g.output(o.output)
+	if err != nil {
+		if o.output != "" {
+			return err
+		}
+		out = settings.OutputText // version is shown even with broken settings, e.g. to report problems with them
+	}
+
+	item := buildInfo()
+	if out != settings.OutputText {
+		return ui.PrintStructured(out, item)
+	}
+
+	fmt.Printf("kubeconfig version %s (commit %s)\n", item.Version, item.Commit)
+	fmt.Printf("go: %s %s/%s\n", item.GoVersion, item.OS, item.Arch)
+	fmt.Printf("registry format: %d, bundle format: %d\n", item.RegistryFormat, item.BundleFormat)
+	if len(item.Modules) > 0 {
+		fmt.Println("modules:")
+		for _, m := range item.Modules {
+			if m.Replace != "" {
+				fmt.Printf("  %s %s => %s\n", m.Path, m.Version, m.Replace)
+			} else {
+				fmt.Printf("  %s %s\n", m.Path, m.Version)
+			}
+		}
+	}
+	return nil
+}
+
+// buildInfo returns the version and build information. Builds made without the release workflow (e.g. with 'go install')
+// are described by information embedded by the Go toolchain.
+func buildInfo() versionItem {
+	item := versionItem{
+		Version:        buildVersion,
+		Commit:         buildCommit,
+		GoVersion:      runtime.Version(),
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		RegistryFormat: registry.FormatVersion,
+		BundleFormat:   registry.BundleVersion,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if item.Version == "development" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			item.Version = info.Main.Version // installed with 'go install'
+		}
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && item.Commit == "?" {
+				item.Commit = s.Value
+			}
+		}
+		for _, d := range info.Deps {
+			m := moduleItem{Path: d.Path, Version: d.Version}
+			if d.Replace != nil {
+				m.Replace = d.Replace.Path + " " + d.Replace.Version
+			}
+			item.Modules = append(item.Modules, m)
+		}
+	}
+	return item
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Marek Dalewski
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/kubeconfig/cmd/ui"
+	"github.com/daishe/kubeconfig/registry"
+	"github.com/daishe/kubeconfig/settings"
+)
+
+const watchLong = `Watches the active kubectl config file and all registries and prints events, as
+they happen: switching to another entry (e.g. by another process), modifications
+of the active kubectl config file, and entries being added, modified or removed.
+
+With '--output json', every event is printed as a single line JSON object.`
+
+// newWatchCmd generates a new watch command.
+func newWatchCmd(global *rootOpts) *cobra.Command {
+	o := &watchOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Print changes of the active kubectl config file and registries",
+		Long:  watchLong,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return watchRun(cmd, global, o)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format (text or json), instead of the one set in settings")
+
+	return cmd
+}
+
+type watchOpts struct {
+	output string
+}
+
+type watchEvent struct {
+	Type     registry.EventType `json:"type"`
+	Time     time.Time          `json:"time"`
+	Name     string             `json:"name,omitempty"`
+	Registry string             `json:"registry,omitempty"`
+	Previous string             `json:"previous,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+func watchRun(cmd *cobra.Command, g *rootOpts, o *watchOpts) error {
+	out, err := g.output(o.output)
+	if err != nil {
+		return err
+	}
+	if out == settings.OutputYAML {
+		return fmt.Errorf("output format %q is not supported for a stream of events, use 'json' instead", out)
+	}
+
+	regs, err := g.registries()
+	if err != nil {
+		return err
+	}
+	kcCfgPath, err := g.kubectlConfigPath()
+	if err != nil {
+		return err
+	}
+
+	events, err := regs.Watch(cmd.Context(), kcCfgPath)
+	if err != nil {
+		return err
+	}
+	if out == settings.OutputText {
+		fmt.Fprintf(os.Stderr, "Watching %q and %d registries (press Ctrl+C to stop).\n", kcCfgPath, len(regs.Sources()))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for e := range events {
+		item := watchEvent{Type: e.Type, Time: e.Time}
+		if e.Entry.Source != nil {
+			item.Name = regs.DisplayName(e.Entry)
+			item.Registry = e.Entry.Source.Name
+		}
+		if e.Previous != nil {
+			item.Previous = regs.DisplayName(*e.Previous)
+		}
+		if e.Err != nil {
+			item.Error = e.Err.Error()
+		}
+
+		if out == settings.OutputJSON {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+			continue
+		}
+		if e.Type == registry.EventError {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", e.Err)
+			continue
+		}
+		fmt.Printf("%s  %s\n", e.Time.Local().Format("15:04:05"), watchMessage(item))
+	}
+	return nil
+}
+
+func watchMessage(e watchEvent) string {
+	from := ""
+	if e.Previous != "" {
+		from = fmt.Sprintf(" (was %q)", e.Previous)
+	}
+	switch e.Type {
+	case registry.EventSwitched:
+		if e.Previous == "" {
+			from = " (was unknown kubectl config)"
+		}
+		return ui.Colorize("green", fmt.Sprintf("Switched to %q", e.Name)) + from
+	case registry.EventActiveModified:
+		return ui.Colorize("yellow", "Active kubectl config modified, it matches no entry") + from
+	case registry.EventActiveRemoved:
+		return ui.Colorize("red", "Active kubectl config removed") + from
+	case registry.EventAdded:
+		return fmt.Sprintf("Entry %q added", e.Name)
+	case registry.EventRemoved:
+		return fmt.Sprintf("Entry %q removed", e.Name)
+	case registry.EventModified:
+		return fmt.Sprintf("Entry %q modified", e.Name)
+	}
+	return string(e.Type)
+}
@@ -0,0 +1,143 @@
+package kubecfg
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Supported API versions of exec based credential plugins.
+const (
+	ExecAPIVersionV1      = "client.authentication.k8s.io/v1"
+	ExecAPIVersionV1Beta1 = "client.authentication.k8s.io/v1beta1"
+)
+
+// ExecInfoEnv is the name of the environment variable passing the ExecCredential request to a credential plugin.
+const ExecInfoEnv = "KUBERNETES_EXEC_INFO"
+
+// ExecCredential is the request passed to and the response returned by an exec based credential plugin.
+type ExecCredential struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Spec       ExecCredentialSpec    `json:"spec"`
+	Status     *ExecCredentialStatus `json:"status,omitempty"`
+}
+
+// ExecCredentialSpec holds information passed to a credential plugin.
+type ExecCredentialSpec struct {
+	Cluster     *ExecCluster `json:"cluster,omitempty"`
+	Interactive bool         `json:"interactive"`
+}
+
+// ExecCluster describes the cluster passed to a credential plugin (when it requests cluster info).
+type ExecCluster struct {
+	Server                   string `json:"server"`
+	TLSServerName            string `json:"tls-server-name,omitempty"`
+	InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify,omitempty"`
+	CertificateAuthorityData []byte `json:"certificate-authority-data,omitempty"`
+	ProxyURL                 string `json:"proxy-url,omitempty"`
+}
+
+// ExecCredentialStatus holds credentials returned by a credential plugin.
+type ExecCredentialStatus struct {
+	ExpirationTimestamp   *time.Time `json:"expirationTimestamp,omitempty"`
+	Token                 string     `json:"token,omitempty"`
+	ClientCertificateData string     `json:"clientCertificateData,omitempty"`
+	ClientKeyData         string     `json:"clientKeyData,omitempty"`
+}
+
+// SupportedAPIVersion reports whether the API version of the plugin is supported by current kubectl releases.
+func (e *ExecConfig) SupportedAPIVersion() bool {
+	return e.APIVersion == ExecAPIVersionV1 || e.APIVersion == ExecAPIVersionV1Beta1
+}
+
+// LookPath returns the path of the plugin binary. As in kubectl, commands being relative paths are resolved against the
+// given directory (of the kubectl config) and plain names are searched in PATH.
+func (e *ExecConfig) LookPath(dir string) (string, error) {
+	if e.Command == "" {
+		return "", fmt.Errorf("no command set")
+	}
+	command := e.Command
+	if !filepath.IsAbs(command) && strings.ContainsAny(command, "/"+string(filepath.Separator)) {
+		command = filepath.Join(dir, command)
+	}
+	return exec.LookPath(command)
+}
+
+// Run invokes the credential plugin the way kubectl does: with the configured arguments and environment, and with the
+// ExecCredential request in the KUBERNETES_EXEC_INFO environment variable. Cluster is passed only when the plugin
+// requests it. In the interactive mode the plugin gets the standard input. Plugin stderr is passed to the given writer.
+// Relative commands are resolved against the given directory (see LookPath).
+func (e *ExecConfig) Run(ctx context.Context, dir string, cluster *Cluster, interactive bool, stderr io.Writer) (*ExecCredential, error) {
+	if !e.SupportedAPIVersion() {
+		return nil, fmt.Errorf("unsupported exec plugin apiVersion %q", e.APIVersion)
+	}
+	path, err := e.LookPath(dir)
+	if err != nil {
+		if e.InstallHint != "" {
+			return nil, fmt.Errorf("%w\n%s", err, e.InstallHint)
+		}
+		return nil, err
+	}
+
+	req := ExecCredential{APIVersion: e.APIVersion, Kind: "ExecCredential", Spec: ExecCredentialSpec{Interactive: interactive}}
+	if e.ProvideClusterInfo && cluster != nil {
+		req.Spec.Cluster = &ExecCluster{
+			Server:                cluster.Server,
+			TLSServerName:         cluster.TLSServerName,
+			InsecureSkipTLSVerify: cluster.InsecureSkipTLSVerify,
+			ProxyURL:              cluster.ProxyURL,
+		}
+		if cluster.CertificateAuthorityData != "" {
+			if req.Spec.Cluster.CertificateAuthorityData, err = base64.StdEncoding.DecodeString(cluster.CertificateAuthorityData); err != nil {
+				return nil, fmt.Errorf("invalid certificate-authority-data: %w", err)
+			}
+		}
+	}
+	info, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %s: %w", ExecInfoEnv, err)
+	}
+
+	cmd := exec.CommandContext(ctx, path, e.Args...)
+	cmd.Env = os.Environ()
+	for _, v := range e.Env {
+		cmd.Env = append(cmd.Env, v.Name+"="+v.Value)
+	}
+	cmd.Env = append(cmd.Env, ExecInfoEnv+"="+string(info))
+	stdout := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if interactive {
+		cmd.Stdin = os.Stdin
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("exec plugin %q failed: %w", e.Command, err)
+	}
+
+	cred := &ExecCredential{}
+	if err := json.Unmarshal(stdout.Bytes(), cred); err != nil {
+		return nil, fmt.Errorf("cannot parse ExecCredential returned by exec plugin %q: %w", e.Command, err)
+	}
+	if cred.Kind != "ExecCredential" {
+		return nil, fmt.Errorf("exec plugin %q returned kind %q instead of ExecCredential", e.Command, cred.Kind)
+	}
+	if cred.APIVersion != e.APIVersion {
+		return nil, fmt.Errorf("exec plugin %q returned apiVersion %q instead of %q", e.Command, cred.APIVersion, e.APIVersion)
+	}
+	if cred.Status == nil {
+		return nil, fmt.Errorf("exec plugin %q returned no status", e.Command)
+	}
+	if cred.Status.Token == "" && (cred.Status.ClientCertificateData == "" || cred.Status.ClientKeyData == "") {
+		return nil, fmt.Errorf("exec plugin %q returned neither a token nor a client certificate and key", e.Command)
+	}
+	return cred, nil
+}
@@ -0,0 +1,159 @@
+package kubecfg
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writePlugin writes a fake credential plugin (a shell script with the given body) to the given directory.
+func writePlugin(t *testing.T, dir string, name string, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake plugins are shell scripts")
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExecConfigRun(t *testing.T) {
+	dir := t.TempDir()
+	infoPath := filepath.Join(dir, "info.json")
+	plugin := writePlugin(t, dir, "plugin", `printf '%s' "$KUBERNETES_EXEC_INFO" > "`+infoPath+`"
+echo "arg=$1 env=$PLUGIN_ENV" >&2
+echo '{"apiVersion": "client.authentication.k8s.io/v1", "kind": "ExecCredential", "status": {"token": "secret", "expirationTimestamp": "2030-01-02T03:04:05Z"}}'`)
+
+	x := &ExecConfig{
+		APIVersion:         ExecAPIVersionV1,
+		Command:            plugin,
+		Args:               []string{"first"},
+		Env:                []ExecEnvVar{{Name: "PLUGIN_ENV", Value: "value"}},
+		ProvideClusterInfo: true,
+	}
+	stderr := &strings.Builder{}
+	cred, err := x.Run(context.Background(), dir, &Cluster{Server: "https://example.com", CertificateAuthorityData: "Y2E="}, false, stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Status.Token != "secret" {
+		t.Errorf("token = %q, want %q", cred.Status.Token, "secret")
+	}
+	if cred.Status.ExpirationTimestamp == nil || cred.Status.ExpirationTimestamp.Year() != 2030 {
+		t.Errorf("expirationTimestamp = %v, want 2030-01-02T03:04:05Z", cred.Status.ExpirationTimestamp)
+	}
+	if got, want := strings.TrimSpace(stderr.String()), "arg=first env=value"; got != want {
+		t.Errorf("plugin stderr = %q, want %q", got, want)
+	}
+
+	b, err := os.ReadFile(infoPath)
+	if err != nil {
+		t.Fatalf("%s not passed: %v", ExecInfoEnv, err)
+	}
+	info := &ExecCredential{}
+	if err := json.Unmarshal(b, info); err != nil {
+		t.Fatalf("cannot parse %s %q: %v", ExecInfoEnv, b, err)
+	}
+	if info.APIVersion != ExecAPIVersionV1 || info.Kind != "ExecCredential" {
+		t.Errorf("%s apiVersion and kind = %q %q, want %q %q", ExecInfoEnv, info.APIVersion, info.Kind, ExecAPIVersionV1, "ExecCredential")
+	}
+	if info.Spec.Interactive {
+		t.Errorf("%s spec.interactive = true, want false", ExecInfoEnv)
+	}
+	if info.Spec.Cluster == nil || info.Spec.Cluster.Server != "https://example.com" || string(info.Spec.Cluster.CertificateAuthorityData) != "ca" {
+		t.Errorf("%s spec.cluster = %+v, want server and decoded certificate authority data", ExecInfoEnv, info.Spec.Cluster)
+	}
+}
+
+func TestExecConfigRunRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiVersion string
+		body       string
+		command    string
+		wantErr    string
+	}{
+		{
+			name:    "wrong apiVersion",
+			body:    `echo '{"apiVersion": "client.authentication.k8s.io/v1beta1", "kind": "ExecCredential", "status": {"token": "t"}}'`,
+			wantErr: "returned apiVersion",
+		},
+		{
+			name:    "wrong kind",
+			body:    `echo '{"apiVersion": "client.authentication.k8s.io/v1", "kind": "Status", "status": {"token": "t"}}'`,
+			wantErr: "returned kind",
+		},
+		{
+			name:    "no credentials",
+			body:    `echo '{"apiVersion": "client.authentication.k8s.io/v1", "kind": "ExecCredential", "status": {}}'`,
+			wantErr: "neither a token nor a client certificate",
+		},
+		{
+			name:    "invalid output",
+			body:    `echo 'not json'`,
+			wantErr: "cannot parse ExecCredential",
+		},
+		{
+			name:    "non-zero exit",
+			body:    `echo '{"apiVersion": "client.authentication.k8s.io/v1", "kind": "ExecCredential", "status": {"token": "t"}}'; exit 3`,
+			wantErr: "exit status 3",
+		},
+		{
+			name:    "missing binary",
+			command: "kubeconfig-test-missing-plugin",
+			wantErr: "executable file not found",
+		},
+		{
+			name:       "unsupported apiVersion",
+			apiVersion: "client.authentication.k8s.io/v1alpha1",
+			body:       `exit 0`,
+			wantErr:    "unsupported exec plugin apiVersion",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			x := &ExecConfig{APIVersion: tt.apiVersion, Command: tt.command}
+			if x.APIVersion == "" {
+				x.APIVersion = ExecAPIVersionV1
+			}
+			if x.Command == "" {
+				x.Command = writePlugin(t, dir, "plugin", tt.body)
+			}
+			_, err := x.Run(context.Background(), dir, nil, false, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Run() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecConfigLookPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "bin"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	plugin := writePlugin(t, filepath.Join(dir, "bin"), "auth", "exit 0")
+
+	x := &ExecConfig{Command: "./bin/auth"}
+	path, err := x.LookPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != plugin {
+		t.Errorf("LookPath(%q) = %q, want %q (resolved against the kubectl config directory)", dir, path, plugin)
+	}
+
+	x = &ExecConfig{Command: "bin/auth"}
+	if path, err := x.LookPath(t.TempDir()); err == nil {
+		t.Errorf("LookPath() = %q, want error for a command missing from the kubectl config directory", path)
+	}
+}
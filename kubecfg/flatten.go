@@ -0,0 +1,58 @@
+package kubecfg
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MissingFile describes a file referenced by a kubectl config, that cannot be read.
+type MissingFile struct {
+	Owner string // e.g. 'cluster "prod"'
+	Field string // e.g. 'certificate-authority'
+	Path  string
+	Err   error
+}
+
+func (m MissingFile) String() string {
+	return fmt.Sprintf("%s: %s %q: %v", m.Owner, m.Field, m.Path, m.Err)
+}
+
+// Flatten inlines files referenced by certificate-authority, client-certificate and client-key fields as the
+// corresponding '*-data' fields. Relative paths are resolved against the given base directory (the directory of the
+// original config file). Files that cannot be read are left referenced and reported. It returns the number of inlined
+// files.
+func (c *Config) Flatten(baseDir string) (int, []MissingFile) {
+	inlined := 0
+	missing := []MissingFile{}
+	inline := func(owner string, field string, path *string, data *string) {
+		if *path == "" {
+			return
+		}
+		p := *path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(baseDir, p)
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			missing = append(missing, MissingFile{Owner: owner, Field: field, Path: p, Err: err})
+			return
+		}
+		*data = base64.StdEncoding.EncodeToString(b)
+		*path = ""
+		inlined++
+	}
+
+	for i := range c.Clusters {
+		cl := &c.Clusters[i]
+		inline(fmt.Sprintf("cluster %q", cl.Name), "certificate-authority", &cl.Cluster.CertificateAuthority, &cl.Cluster.CertificateAuthorityData)
+	}
+	for i := range c.Users {
+		u := &c.Users[i]
+		owner := fmt.Sprintf("user %q", u.Name)
+		inline(owner, "client-certificate", &u.User.ClientCertificate, &u.User.ClientCertificateData)
+		inline(owner, "client-key", &u.User.ClientKey, &u.User.ClientKeyData)
+	}
+	return inlined, missing
+}
@@ -0,0 +1,266 @@
+package kubecfg
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a kubectl config file. Fields not known to kubeconfig are preserved.
+type Config struct {
+	APIVersion     string         `yaml:"apiVersion,omitempty"`
+	Kind           string         `yaml:"kind,omitempty"`
+	Clusters       []NamedCluster `yaml:"clusters"`
+	Users          []NamedUser    `yaml:"users"`
+	Contexts       []NamedContext `yaml:"contexts"`
+	CurrentContext string         `yaml:"current-context"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// NamedCluster is a cluster entry of a kubectl config file.
+type NamedCluster struct {
+	Name    string  `yaml:"name"`
+	Cluster Cluster `yaml:"cluster"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// Cluster describes how to communicate with a Kubernetes cluster.
+type Cluster struct {
+	Server                   string `yaml:"server,omitempty"`
+	TLSServerName            string `yaml:"tls-server-name,omitempty"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify,omitempty"`
+	CertificateAuthority     string `yaml:"certificate-authority,omitempty"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data,omitempty"`
+	ProxyURL                 string `yaml:"proxy-url,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// NamedUser is a user entry of a kubectl config file.
+type NamedUser struct {
+	Name string `yaml:"name"`
+	User User   `yaml:"user"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// User describes credentials used to authenticate to a Kubernetes cluster.
+type User struct {
+	ClientCertificate     string      `yaml:"client-certificate,omitempty"`
+	ClientCertificateData string      `yaml:"client-certificate-data,omitempty"`
+	ClientKey             string      `yaml:"client-key,omitempty"`
+	ClientKeyData         string      `yaml:"client-key-data,omitempty"`
+	Token                 string      `yaml:"token,omitempty"`
+	TokenFile             string      `yaml:"tokenFile,omitempty"`
+	Username              string      `yaml:"username,omitempty"`
+	Password              string      `yaml:"password,omitempty"`
+	Exec                  *ExecConfig `yaml:"exec,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// ExecConfig describes an exec based credential plugin.
+type ExecConfig struct {
+	APIVersion         string       `yaml:"apiVersion,omitempty"`
+	Command            string       `yaml:"command"`
+	Args               []string     `yaml:"args,omitempty"`
+	Env                []ExecEnvVar `yaml:"env,omitempty"`
+	InstallHint        string       `yaml:"installHint,omitempty"`
+	ProvideClusterInfo bool         `yaml:"provideClusterInfo,omitempty"`
+	InteractiveMode    string       `yaml:"interactiveMode,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// ExecEnvVar is an environment variable passed to an exec based credential plugin.
+type ExecEnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// NamedContext is a context entry of a kubectl config file.
+type NamedContext struct {
+	Name    string  `yaml:"name"`
+	Context Context `yaml:"context"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// Context ties together a cluster, a user and a namespace.
+type Context struct {
+	Cluster   string `yaml:"cluster"`
+	User      string `yaml:"user"`
+	Namespace string `yaml:"namespace,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// Parse parses the content of a kubectl config file.
+func Parse(b []byte) (*Config, error) {
+	c := &Config{}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return c, nil
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("cannot parse kubectl config: %w", err)
+	}
+	return c, nil
+}
+
+// Read reads and parses the kubectl config file from the given reader.
+func Read(r io.Reader) (*Config, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read kubectl config: %w", err)
+	}
+	return Parse(b)
+}
+
+// ReadFile reads and parses the kubectl config file under the given path.
+func ReadFile(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file %q: %w", path, err)
+	}
+	c, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("file %q: %w", path, err)
+	}
+	return c, nil
+}
+
+// Encode encodes the kubectl config.
+func (c *Config) Encode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, fmt.Errorf("cannot encode kubectl config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("cannot encode kubectl config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Cluster returns the cluster with the given name.
+func (c *Config) Cluster(name string) (*NamedCluster, bool) {
+	for i := range c.Clusters {
+		if c.Clusters[i].Name == name {
+			return &c.Clusters[i], true
+		}
+	}
+	return nil, false
+}
+
+// User returns the user with the given name.
+func (c *Config) User(name string) (*NamedUser, bool) {
+	for i := range c.Users {
+		if c.Users[i].Name == name {
+			return &c.Users[i], true
+		}
+	}
+	return nil, false
+}
+
+// Context returns the context with the given name.
+func (c *Config) Context(name string) (*NamedContext, bool) {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i], true
+		}
+	}
+	return nil, false
+}
+
+// CertificateExpiry returns the expiration time of the embedded client certificate. It reports false, when the user
+// has no embedded client certificate.
+func (u *User) CertificateExpiry() (time.Time, bool, error) {
+	if u.ClientCertificateData == "" {
+		return time.Time{}, false, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(u.ClientCertificateData)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("cannot decode client certificate data: %w", err)
+	}
+	cert, err := parseCertificate(data)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return cert.NotAfter, true, nil
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("cannot decode client certificate: no PEM data")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse client certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// Expiry returns the earliest expiration time of embedded client certificates of all users. It reports false, when
+// there are no embedded client certificates.
+func (c *Config) Expiry() (time.Time, bool) {
+	var earliest time.Time
+	found := false
+	for _, u := range c.Users {
+		t, ok, err := u.User.CertificateExpiry()
+		if err != nil || !ok {
+			continue
+		}
+		if !found || t.Before(earliest) {
+			earliest, found = t, true
+		}
+	}
+	return earliest, found
+}
+
+// Minify returns a new kubectl config containing only the given context (or the current one, if empty) together with
+// the cluster and the user it references.
+func (c *Config) Minify(context string) (*Config, error) {
+	if context == "" {
+		context = c.CurrentContext
+	}
+	if context == "" {
+		return nil, fmt.Errorf("no context given and the current context is not set")
+	}
+	ctx, ok := c.Context(context)
+	if !ok {
+		return nil, fmt.Errorf("context %q does not exist", context)
+	}
+
+	m := &Config{APIVersion: c.APIVersion, Kind: c.Kind, CurrentContext: ctx.Name, Contexts: []NamedContext{*ctx}}
+	if m.APIVersion == "" {
+		m.APIVersion = "v1"
+	}
+	if m.Kind == "" {
+		m.Kind = "Config"
+	}
+	cl, ok := c.Cluster(ctx.Context.Cluster)
+	if !ok {
+		return nil, fmt.Errorf("cluster %q referenced by context %q does not exist", ctx.Context.Cluster, ctx.Name)
+	}
+	m.Clusters = []NamedCluster{*cl}
+	if ctx.Context.User != "" {
+		u, ok := c.User(ctx.Context.User)
+		if !ok {
+			return nil, fmt.Errorf("user %q referenced by context %q does not exist", ctx.Context.User, ctx.Name)
+		}
+		m.Users = []NamedUser{*u}
+	}
+	return m, nil
+}
@@ -0,0 +1,47 @@
+package kubecfg
+
+// Placeholders used by Redact (the same as used by 'kubectl config view').
+const (
+	RedactedSecret = "REDACTED"
+	RedactedData   = "DATA+OMITTED"
+)
+
+// Redact replaces secrets (tokens, passwords, private keys, environment of credential plugins and auth provider
+// configuration) and embedded certificates with placeholders.
+func (c *Config) Redact() {
+	for i := range c.Clusters {
+		cl := &c.Clusters[i].Cluster
+		redactData(&cl.CertificateAuthorityData)
+	}
+	for i := range c.Users {
+		u := &c.Users[i].User
+		redactData(&u.ClientCertificateData)
+		redactSecret(&u.ClientKeyData)
+		redactSecret(&u.Token)
+		redactSecret(&u.Password)
+		if u.Exec != nil {
+			for j := range u.Exec.Env {
+				redactSecret(&u.Exec.Env[j].Value)
+			}
+		}
+		if ap, ok := u.Extra["auth-provider"].(map[string]interface{}); ok {
+			if cfg, ok := ap["config"].(map[string]interface{}); ok {
+				for k := range cfg {
+					cfg[k] = RedactedSecret
+				}
+			}
+		}
+	}
+}
+
+func redactSecret(s *string) {
+	if *s != "" {
+		*s = RedactedSecret
+	}
+}
+
+func redactData(s *string) {
+	if *s != "" {
+		*s = RedactedData
+	}
+}
@@ -0,0 +1,287 @@
+package kubecfg
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RewriteClusters applies fn to every cluster of the given kubectl config content and returns the new content. Only
+// the server, tls-server-name and proxy-url fields changed by fn are edited: lines holding them are changed in place,
+// so the rest of the file is kept byte for byte. When that is not possible (e.g. for flow style mappings), the edited
+// YAML document is encoded again, which keeps comments, key order and quoting, but not indentation. It reports whether
+// anything changed (when nothing did, the original content is returned).
+func RewriteClusters(b []byte, fn func(c *Cluster)) ([]byte, bool, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, false, fmt.Errorf("cannot parse kubectl config: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return b, false, nil
+	}
+	clusters := mappingValue(doc.Content[0], "clusters")
+	if clusters == nil || clusters.Kind != yaml.SequenceNode {
+		return b, false, nil
+	}
+
+	lines := lineOffsets(b)
+	edits := []textEdit{}
+	spliced := true
+	changed := false
+	for _, item := range clusters.Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		node := mappingValue(item, "cluster")
+		if node == nil || node.Kind != yaml.MappingNode {
+			continue
+		}
+		c := Cluster{}
+		if err := node.Decode(&c); err != nil {
+			return nil, false, fmt.Errorf("cannot parse kubectl config: %w", err)
+		}
+		before := c
+		fn(&c)
+		for _, f := range []struct {
+			key    string
+			before string
+			after  string
+		}{
+			{"server", before.Server, c.Server},
+			{"tls-server-name", before.TLSServerName, c.TLSServerName},
+			{"proxy-url", before.ProxyURL, c.ProxyURL},
+		} {
+			if f.before != f.after {
+				e, ok := spliceMappingString(b, lines, node, f.key, f.after)
+				edits = append(edits, e)
+				spliced = spliced && ok
+				setMappingString(node, f.key, f.after)
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return b, false, nil
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, false, fmt.Errorf("cannot encode kubectl config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, false, fmt.Errorf("cannot encode kubectl config: %w", err)
+	}
+	if spliced {
+		// use the in place edit only when it means exactly the same as the encoded document
+		if out, ok := applyEdits(b, edits); ok {
+			want, err := Parse(buf.Bytes())
+			got, err2 := Parse(out)
+			if err == nil && err2 == nil && reflect.DeepEqual(got, want) {
+				return out, true, nil
+			}
+		}
+	}
+	return buf.Bytes(), true, nil
+}
+
+// textEdit replaces bytes from start to end (exclusive) with the given text.
+type textEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// applyEdits applies the given edits to b. It reports false, when edits overlap.
+func applyEdits(b []byte, edits []textEdit) ([]byte, bool) {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	out := &bytes.Buffer{}
+	pos := 0
+	for _, e := range edits {
+		if e.start < pos {
+			return nil, false
+		}
+		out.Write(b[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.Write(b[pos:])
+	return out.Bytes(), true
+}
+
+// lineOffsets returns offsets of beginnings of all lines of b.
+func lineOffsets(b []byte) []int {
+	lines := []int{0}
+	for i, c := range b {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// spliceMappingString returns the in place edit of b setting the string value of the given key in the block mapping
+// node (see setMappingString). It reports false, when the edit is not possible.
+func spliceMappingString(b []byte, lines []int, m *yaml.Node, key string, value string) (textEdit, bool) {
+	if m.Style&yaml.FlowStyle != 0 {
+		return textEdit{}, false
+	}
+	text := ""
+	if value != "" {
+		style := yaml.Style(0)
+		if v := mappingValue(m, key); v != nil {
+			style = v.Style
+		}
+		var ok bool
+		if text, ok = encodeScalar(value, style); !ok {
+			return textEdit{}, false
+		}
+	}
+
+	var last *yaml.Node
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k, v := m.Content[i], m.Content[i+1]
+		start, end, ok := scalarExtent(b, lines, v)
+		ok = ok && k.Line == v.Line && onlyIndent(b, lines, k)
+		if ok {
+			last = k
+		}
+		if k.Value != key {
+			continue
+		}
+		if !ok {
+			return textEdit{}, false
+		}
+		if value == "" {
+			return textEdit{start: lines[k.Line-1], end: lineEnd(b, lines, k.Line)}, true
+		}
+		return textEdit{start: start, end: end, text: text}, true
+	}
+	if value == "" {
+		return textEdit{}, true // nothing to remove
+	}
+	if last == nil {
+		return textEdit{}, false
+	}
+	at := lineEnd(b, lines, last.Line)
+	if at == len(b) && (len(b) == 0 || b[len(b)-1] != '\n') {
+		return textEdit{}, false
+	}
+	k, ok := encodeScalar(key, 0)
+	if !ok {
+		return textEdit{}, false
+	}
+	return textEdit{start: at, end: at, text: strings.Repeat(" ", last.Column-1) + k + ": " + text + "\n"}, true
+}
+
+// encodeScalar encodes the given string as a single line YAML scalar in the given style. It reports false, when that
+// is not possible.
+func encodeScalar(value string, style yaml.Style) (string, bool) {
+	if style != 0 && style != yaml.DoubleQuotedStyle && style != yaml.SingleQuotedStyle {
+		style = 0
+	}
+	out, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: style})
+	if err != nil {
+		return "", false
+	}
+	text := strings.TrimSuffix(string(out), "\n")
+	return text, !strings.Contains(text, "\n")
+}
+
+// scalarExtent returns offsets of the beginning and the end (exclusive) of the given single line scalar node in b. It
+// reports false for other nodes.
+func scalarExtent(b []byte, lines []int, n *yaml.Node) (int, int, bool) {
+	if n.Kind != yaml.ScalarNode || n.Line < 1 || n.Line > len(lines) {
+		return 0, 0, false
+	}
+	start := lines[n.Line-1] + n.Column - 1
+	end := lineEnd(b, lines, n.Line)
+	if start >= end {
+		return 0, 0, false
+	}
+	switch n.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := start + 1; i < end; i++ {
+			switch b[i] {
+			case '\\':
+				i++
+			case '"':
+				return start, i + 1, b[start] == '"'
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := start + 1; i < end; i++ {
+			if b[i] != '\'' {
+				continue
+			}
+			if i+1 < end && b[i+1] == '\'' {
+				i++
+				continue
+			}
+			return start, i + 1, b[start] == '\''
+		}
+	case 0:
+		line := string(b[start:end])
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimRight(line, " \t\r\n")
+		return start, start + len(line), line == n.Value
+	}
+	return 0, 0, false
+}
+
+// onlyIndent reports whether the given node is preceded only by spaces on its line.
+func onlyIndent(b []byte, lines []int, n *yaml.Node) bool {
+	start := lines[n.Line-1]
+	end := start + n.Column - 1
+	return end <= len(b) && strings.Trim(string(b[start:end]), " ") == ""
+}
+
+// lineEnd returns the offset of the beginning of the line following the given one (1-based), or the length of b for
+// the last line.
+func lineEnd(b []byte, lines []int, line int) int {
+	if line < len(lines) {
+		return lines[line]
+	}
+	return len(b)
+}
+
+// mappingValue returns the value node of the given key in the mapping node (nil, when there is no such key).
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingString sets the string value of the given key in the mapping node, keeping the style of an existing value.
+// An empty value removes the key (like the omitempty fields of Cluster).
+func setMappingString(m *yaml.Node, key string, value string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != key {
+			continue
+		}
+		if value == "" {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+		v := m.Content[i+1]
+		v.Kind, v.Tag, v.Value, v.Content = yaml.ScalarNode, "!!str", value, nil
+		return
+	}
+	if value == "" {
+		return
+	}
+	m.Content = append(m.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+	)
+}
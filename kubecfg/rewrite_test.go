@@ -0,0 +1,97 @@
+package kubecfg
+
+import (
+	"testing"
+)
+
+func TestRewriteClusters(t *testing.T) {
+	const config = `# comment kept
+clusters:
+- name: prod
+  cluster:
+    server: "https://old.example.com:6443" # api
+    proxy-url: socks5://old:1080
+    certificate-authority-data: AAAA
+users: []
+`
+	tests := []struct {
+		name string
+		in   string
+		fn   func(c *Cluster)
+		want string
+	}{
+		{
+			name: "replace keeps quoting and comments",
+			in:   config,
+			fn:   func(c *Cluster) { c.Server = "https://new.example.com:6443" },
+			want: `# comment kept
+clusters:
+- name: prod
+  cluster:
+    server: "https://new.example.com:6443" # api
+    proxy-url: socks5://old:1080
+    certificate-authority-data: AAAA
+users: []
+`,
+		},
+		{
+			name: "remove",
+			in:   config,
+			fn:   func(c *Cluster) { c.ProxyURL = "" },
+			want: `# comment kept
+clusters:
+- name: prod
+  cluster:
+    server: "https://old.example.com:6443" # api
+    certificate-authority-data: AAAA
+users: []
+`,
+		},
+		{
+			name: "add",
+			in:   config,
+			fn:   func(c *Cluster) { c.TLSServerName = "true" },
+			want: `# comment kept
+clusters:
+- name: prod
+  cluster:
+    server: "https://old.example.com:6443" # api
+    proxy-url: socks5://old:1080
+    certificate-authority-data: AAAA
+    tls-server-name: "true"
+users: []
+`,
+		},
+		{
+			name: "flow style is encoded again",
+			in:   "clusters: [{name: a, cluster: {server: https://x}}] # c\n",
+			fn:   func(c *Cluster) { c.Server = "https://y" },
+			want: "clusters: [{name: a, cluster: {server: 'https://y'}}] # c\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := RewriteClusters([]byte(tt.in), tt.fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !changed {
+				t.Fatal("RewriteClusters() changed = false, want true")
+			}
+			if string(got) != tt.want {
+				t.Errorf("RewriteClusters() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteClustersUnchanged(t *testing.T) {
+	in := []byte("clusters:\n- name: a\n  cluster:\n    server: https://x\n")
+	got, changed, err := RewriteClusters(in, func(c *Cluster) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || string(got) != string(in) {
+		t.Errorf("RewriteClusters() = %q, %v, want the original content unchanged", got, changed)
+	}
+}
@@ -21,10 +21,11 @@ import (
 )
 
 var (
-	Version = "development" //nolint:deadcode
-	Commit  = "?"           //nolint:deadcode
+	Version = "development"
+	Commit  = "?"
 )
 
 func main() {
+	cmd.SetBuildInfo(Version, Commit)
 	cmd.Execute(context.Background())
 }
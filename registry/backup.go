@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// Backup stores a copy of the given content in the backup directory under the provided name and removes the oldest
+// backups of that name exceeding the keep limit. When keep is zero, backups are disabled and nothing is stored.
+func Backup(dir string, name string, content io.Reader, keep int) (string, error) {
+	if keep <= 0 {
+		return "", nil
+	}
+
+	path := filepath.Join(NameToPath(dir, name), time.Now().UTC().Format(backupTimeFormat))
+	if err := writeWithFlag(path, content, os.O_RDWR|os.O_CREATE|os.O_EXCL); err != nil {
+		return "", fmt.Errorf("backup failed: %w", err)
+	}
+
+	return path, PruneBackups(dir, name, keep)
+}
+
+// BackupFile stores a copy of the file under the given path in the backup directory (see Backup). Missing file is not
+// considered an error and results in no backup.
+func BackupFile(dir string, name string, path string, keep int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("cannot open file %q: %w", path, err)
+	}
+	defer f.Close()
+	return Backup(dir, name, f, keep)
+}
+
+// ListBackups returns paths of all backups stored under the given name, from the oldest to the newest.
+func ListBackups(dir string, name string) ([]string, error) {
+	path := NameToPath(dir, name)
+	files, err := os.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("connot read directory %q: %w", path, err)
+	}
+
+	res := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.Type().IsRegular() {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, f.Name()); err != nil {
+			continue
+		}
+		res = append(res, filepath.Join(path, f.Name()))
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// BackupTime returns the time, the backup under the given path was made.
+func BackupTime(path string) (time.Time, error) {
+	t, err := time.Parse(backupTimeFormat, strings.TrimSpace(filepath.Base(path)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a backup file: %w", path, err)
+	}
+	return t, nil
+}
+
+// PruneBackups removes the oldest backups stored under the given name exceeding the keep limit.
+func PruneBackups(dir string, name string, keep int) error {
+	ls, err := ListBackups(dir, name)
+	if err != nil {
+		return err
+	}
+	for len(ls) > keep {
+		if err := os.Remove(ls[0]); err != nil {
+			return fmt.Errorf("cannot remove backup %q: %w", ls[0], err)
+		}
+		ls = ls[1:]
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+	"gopkg.in/yaml.v3"
+)
+
+// BundleVersion is the version of the bundle format written by WriteBundle.
+const BundleVersion = 1
+
+const (
+	bundleManifest   = "manifest.yaml"
+	bundleEntriesDir = "entries"
+	bundleMagic      = "kubeconfig-bundle-encrypted-v1\n"
+	bundleSaltSize   = 16
+
+	// limits of files read from bundles, so that crafted archives cannot expand without bound
+	bundleMaxFileSize  = 16 << 20
+	bundleMaxTotalSize = 256 << 20
+)
+
+// ErrPassphraseRequired is returned when reading an encrypted bundle without a passphrase.
+var ErrPassphraseRequired = errors.New("bundle is encrypted, passphrase required")
+
+// Manifest describes the content of a bundle.
+type Manifest struct {
+	Version int             `yaml:"version"`
+	Created time.Time       `yaml:"created"`
+	Entries []ManifestEntry `yaml:"entries"`
+}
+
+// ManifestEntry describes a single entry stored in a bundle.
+type ManifestEntry struct {
+	Name     string         `yaml:"name"`
+	Registry string         `yaml:"registry"`
+	Hash     string         `yaml:"sha3-256"`
+	Metadata *EntryMetadata `yaml:"metadata,omitempty"`
+}
+
+// BundleEntry is a single entry read from a bundle.
+type BundleEntry struct {
+	ManifestEntry
+	Content []byte
+}
+
+func (m ManifestEntry) archivePath() string {
+	return path.Join(bundleEntriesDir, m.Registry, m.Name)
+}
+
+// WriteBundle writes the given entries (together with their metadata) as a gzip compressed tar archive with a manifest.
+// When the passphrase is not empty, the archive is encrypted.
+func (r *Registries) WriteBundle(w io.Writer, entries []Entry, passphrase string) error {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now().UTC()
+
+	m := Manifest{Version: BundleVersion, Created: now}
+	files := map[string][]byte{}
+	for _, e := range entries {
+		b, err := readFile(e.Path)
+		if err != nil {
+			return err
+		}
+		meta, err := r.Metadata(e)
+		if err != nil {
+			return err
+		}
+		me := ManifestEntry{Name: e.Name, Registry: e.Source.Name, Hash: hashBytes(b)}
+		if !meta.empty() {
+			me.Metadata = meta
+		}
+		m.Entries = append(m.Entries, me)
+		files[me.archivePath()] = b
+	}
+
+	manifest := &bytes.Buffer{}
+	enc := yaml.NewEncoder(manifest)
+	enc.SetIndent(2)
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("cannot encode manifest: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("cannot encode manifest: %w", err)
+	}
+	if err := writeTarFile(tw, bundleManifest, manifest.Bytes(), now); err != nil {
+		return err
+	}
+	for _, me := range m.Entries {
+		if err := writeTarFile(tw, me.archivePath(), files[me.archivePath()], now); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("cannot write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("cannot write archive: %w", err)
+	}
+
+	content := buf.Bytes()
+	if passphrase != "" {
+		var err error
+		if content, err = encryptBundle(content, passphrase); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("cannot write bundle: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle reads a bundle written by WriteBundle and verifies hashes of all entries against its manifest. Files in the
+// bundle are limited in size.
+func ReadBundle(rd io.Reader, passphrase string) (*Manifest, []BundleEntry, error) {
+	content, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read bundle: %w", err)
+	}
+	if bytes.HasPrefix(content, []byte(bundleMagic)) {
+		if passphrase == "" {
+			return nil, nil, ErrPassphraseRequired
+		}
+		if content, err = decryptBundle(content, passphrase); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	total := 0
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read bundle: %w", err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(io.LimitReader(tr, bundleMaxFileSize+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read bundle: %w", err)
+		}
+		if len(b) > bundleMaxFileSize {
+			return nil, nil, fmt.Errorf("cannot read bundle: file %q exceeds %d bytes", h.Name, bundleMaxFileSize)
+		}
+		if total += len(b); total > bundleMaxTotalSize {
+			return nil, nil, fmt.Errorf("cannot read bundle: content exceeds %d bytes", bundleMaxTotalSize)
+		}
+		files[path.Clean(h.Name)] = b
+	}
+
+	b, ok := files[bundleManifest]
+	if !ok {
+		return nil, nil, fmt.Errorf("bundle has no manifest")
+	}
+	m := &Manifest{}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return nil, nil, fmt.Errorf("cannot parse bundle manifest: %w", err)
+	}
+	if m.Version > BundleVersion {
+		return nil, nil, fmt.Errorf("bundle format version %d is not supported (newest supported is %d)", m.Version, BundleVersion)
+	}
+
+	entries := make([]BundleEntry, 0, len(m.Entries))
+	for _, me := range m.Entries {
+		if err := ValidateName(me.Name); err != nil {
+			return nil, nil, fmt.Errorf("invalid entry in bundle: %w", err)
+		}
+		b, ok := files[me.archivePath()]
+		if !ok {
+			return nil, nil, fmt.Errorf("entry %q is missing in bundle", me.Name)
+		}
+		if h := hashBytes(b); h != me.Hash {
+			return nil, nil, fmt.Errorf("integrity check of entry %q failed: hash %s does not match %s in manifest", me.Name, h, me.Hash)
+		}
+		entries = append(entries, BundleEntry{ManifestEntry: me, Content: b})
+	}
+	return m, entries, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, content []byte, t time.Time) error {
+	h := &tar.Header{Name: name, Mode: 0640, Size: int64(len(content)), ModTime: t, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(h); err != nil {
+		return fmt.Errorf("cannot write archive: %w", err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		return fmt.Errorf("cannot write archive: %w", err)
+	}
+	return nil
+}
+
+func bundleKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive key: %w", err)
+	}
+	return key, nil
+}
+
+// encryptBundle encrypts the given content with XChaCha20-Poly1305, using a key derived from the passphrase with scrypt.
+// The result is laid out as: magic, salt, nonce, ciphertext.
+func encryptBundle(content []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, bundleSaltSize)
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("cannot generate salt: %w", err)
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("cannot generate nonce: %w", err)
+	}
+	key, err := bundleKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot set up encryption: %w", err)
+	}
+	header := append(append([]byte(bundleMagic), salt...), nonce...)
+	return aead.Seal(header, nonce, content, header), nil
+}
+
+func decryptBundle(content []byte, passphrase string) ([]byte, error) {
+	headerSize := len(bundleMagic) + bundleSaltSize + chacha20poly1305.NonceSizeX
+	if len(content) < headerSize {
+		return nil, fmt.Errorf("encrypted bundle is truncated")
+	}
+	header := content[:headerSize]
+	salt := header[len(bundleMagic) : len(bundleMagic)+bundleSaltSize]
+	nonce := header[len(bundleMagic)+bundleSaltSize:]
+	key, err := bundleKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot set up decryption: %w", err)
+	}
+	plain, err := aead.Open(nil, nonce, content[headerSize:], header)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt bundle (wrong passphrase or corrupted bundle)")
+	}
+	return plain, nil
+}
+
+func hashBytes(b []byte) string {
+	h := sha3.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func readFile(path string) ([]byte, error) {
+	f, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file %q: %w", path, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,179 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRegistries returns registries with a single registry (in a temporary directory) holding the given entries.
+func newTestRegistries(t *testing.T, entries map[string]string) *Registries {
+	t.Helper()
+	regs, err := NewRegistries([]*Source{{Name: "default", Path: t.TempDir()}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range entries {
+		e, err := regs.Target(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := regs.Write(e, strings.NewReader(content), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return regs
+}
+
+// testBundle returns a plain bundle with the given manifest and files.
+func testBundle(t *testing.T, manifest string, files map[string]string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	if err := writeTarFile(tw, bundleManifest, []byte(manifest), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := writeTarFile(tw, name, []byte(content), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	entries := map[string]string{"dev": "dev config\n", "aws/prod": "prod config\n"}
+	regs := newTestRegistries(t, entries)
+	prod, err := regs.Lookup("aws/prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := regs.SetMetadata(prod, &EntryMetadata{Labels: map[string]string{"env": "prod"}, Protected: true}); err != nil {
+		t.Fatal(err)
+	}
+	ls, err := regs.List(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, passphrase := range []string{"", "secret"} {
+		name := "plain"
+		if passphrase != "" {
+			name = "encrypted"
+		}
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := regs.WriteBundle(buf, ls, passphrase); err != nil {
+				t.Fatal(err)
+			}
+			if got := bytes.HasPrefix(buf.Bytes(), []byte(bundleMagic)); got != (passphrase != "") {
+				t.Errorf("bundle encrypted = %v, want %v", got, passphrase != "")
+			}
+			if passphrase != "" && bytes.Contains(buf.Bytes(), []byte("prod config")) {
+				t.Error("encrypted bundle contains entry content in plain text")
+			}
+
+			m, read, err := ReadBundle(bytes.NewReader(buf.Bytes()), passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Version != BundleVersion || len(read) != len(entries) {
+				t.Fatalf("ReadBundle() version %d with %d entries, want version %d with %d entries", m.Version, len(read), BundleVersion, len(entries))
+			}
+			for _, be := range read {
+				if be.Registry != "default" || string(be.Content) != entries[be.Name] {
+					t.Errorf("entry %q from registry %q content = %q, want %q from registry %q", be.Name, be.Registry, be.Content, entries[be.Name], "default")
+				}
+				if be.Name == "aws/prod" && (be.Metadata == nil || !be.Metadata.Protected || be.Metadata.Labels["env"] != "prod") {
+					t.Errorf("entry %q metadata = %+v, want protected with label env=prod", be.Name, be.Metadata)
+				}
+			}
+		})
+	}
+}
+
+func TestReadBundleWrongPassphrase(t *testing.T) {
+	regs := newTestRegistries(t, map[string]string{"dev": "dev config\n"})
+	ls, err := regs.List(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := regs.WriteBundle(buf, ls, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ReadBundle(bytes.NewReader(buf.Bytes()), ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("ReadBundle() without passphrase error = %v, want %v", err, ErrPassphraseRequired)
+	}
+	if _, _, err := ReadBundle(bytes.NewReader(buf.Bytes()), "wrong"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("ReadBundle() with wrong passphrase error = %v, want error containing %q", err, "wrong passphrase")
+	}
+}
+
+func TestReadBundleRejects(t *testing.T) {
+	const content = "dev config\n"
+	manifest := func(name string, hash string) string {
+		return "version: 1\nentries:\n- name: " + name + "\n  registry: default\n  sha3-256: " + hash + "\n"
+	}
+	tests := []struct {
+		name    string
+		bundle  []byte
+		wantErr string
+	}{
+		{
+			name:    "tampered hash",
+			bundle:  testBundle(t, manifest("dev", hashBytes([]byte("other"))), map[string]string{"entries/default/dev": content}),
+			wantErr: "integrity check",
+		},
+		{
+			name:    "tampered content",
+			bundle:  testBundle(t, manifest("dev", hashBytes([]byte(content))), map[string]string{"entries/default/dev": "changed\n"}),
+			wantErr: "integrity check",
+		},
+		{
+			name:    "invalid entry name",
+			bundle:  testBundle(t, manifest("../dev", hashBytes([]byte(content))), map[string]string{"entries/dev": content}),
+			wantErr: "invalid entry",
+		},
+		{
+			name:    "missing entry",
+			bundle:  testBundle(t, manifest("dev", hashBytes([]byte(content))), nil),
+			wantErr: "missing",
+		},
+		{
+			name:    "missing manifest",
+			bundle:  testBundle(t, "", nil)[:0],
+			wantErr: "cannot read bundle",
+		},
+		{
+			name:    "newer version",
+			bundle:  testBundle(t, "version: 999\n", nil),
+			wantErr: "not supported",
+		},
+		{
+			name:    "oversized file",
+			bundle:  testBundle(t, manifest("dev", ""), map[string]string{"entries/default/dev": strings.Repeat("x", bundleMaxFileSize+1)}),
+			wantErr: "exceeds",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ReadBundle(bytes.NewReader(tt.bundle), "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadBundle() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
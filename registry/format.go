@@ -0,0 +1,181 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile is the name of the file (in the registry root directory) describing the format of the registry.
+const ManifestFile = ".registry.yaml"
+
+// RegistryManifest describes the format of a single registry. Registries without the manifest file are legacy plain
+// directories of kubectl config files (format version 0).
+type RegistryManifest struct {
+	Format int `yaml:"format"`
+}
+
+// ErrNewerFormat is returned (wrapped) when a registry uses a format newer than the one supported by this build.
+var ErrNewerFormat = errors.New("newer format")
+
+// ReadRegistryManifest reads the manifest of the given registry. Missing manifest file (or missing registry directory)
+// results in the manifest of a legacy registry (format version 0).
+func ReadRegistryManifest(registry string) (*RegistryManifest, error) {
+	path := filepath.Join(registry, ManifestFile)
+	m := &RegistryManifest{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, fmt.Errorf("cannot read registry manifest %q: %w", path, err)
+	}
+	if err := yaml.NewDecoder(bytes.NewReader(b)).Decode(m); err != nil && len(bytes.TrimSpace(b)) > 0 {
+		return nil, fmt.Errorf("cannot parse registry manifest %q: %w", path, err)
+	}
+	if m.Format < 0 {
+		return nil, fmt.Errorf("registry manifest %q has invalid format version %d", path, m.Format)
+	}
+	return m, nil
+}
+
+// WriteRegistryManifest writes the manifest of the given registry.
+func WriteRegistryManifest(registry string, m *RegistryManifest) error {
+	path := filepath.Join(registry, ManifestFile)
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("cannot encode registry manifest: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("cannot encode registry manifest: %w", err)
+	}
+	return ForceWrite(path, buf)
+}
+
+// CheckFormat returns an error, when the given registry uses a format newer than the one supported by this build.
+func CheckFormat(s *Source) error {
+	m, err := ReadRegistryManifest(s.Path)
+	if err != nil {
+		return err
+	}
+	if m.Format > FormatVersion {
+		return fmt.Errorf("registry %q uses %w (version %d, this build supports up to %d); Upgrade kubeconfig to use it", s.Name, ErrNewerFormat, m.Format, FormatVersion)
+	}
+	return nil
+}
+
+// Migration describes a single step upgrading the registry format from one version to the next.
+type Migration struct {
+	From        int
+	To          int
+	Description string
+	apply       func(registry string) error
+}
+
+// migrations are all steps upgrading registries, in order. The step upgrading from version N is the N-th element.
+var migrations = []Migration{
+	{From: 0, To: 1, Description: "add registry manifest to the legacy plain-directory registry", apply: func(string) error { return nil }},
+}
+
+// PendingMigrations returns steps needed to upgrade the given registry to the format supported by this build (none, when
+// the registry is up to date).
+func PendingMigrations(s *Source) ([]Migration, error) {
+	if err := CheckFormat(s); err != nil {
+		return nil, err
+	}
+	m, err := ReadRegistryManifest(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return migrations[m.Format:FormatVersion], nil
+}
+
+// Migrate upgrades the given registry in place to the format supported by this build. The manifest is updated after
+// every step, so an interrupted migration continues from the last completed step.
+func Migrate(s *Source) error {
+	if s.ReadOnly {
+		return fmt.Errorf("cannot migrate registry %q, it is read-only", s.Name)
+	}
+	steps, err := PendingMigrations(s)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if err := step.apply(s.Path); err != nil {
+			return fmt.Errorf("migrating registry %q from format version %d to %d filed: %w", s.Name, step.From, step.To, err)
+		}
+		if err := WriteRegistryManifest(s.Path, &RegistryManifest{Format: step.To}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Init creates the directory of the given registry (with the manifest of the current format), unless it already exists.
+// Registries created this way never need migrating.
+func Init(s *Source) error {
+	if _, err := os.Stat(s.Path); !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return WriteRegistryManifest(s.Path, &RegistryManifest{Format: FormatVersion})
+}
+
+// ArchiveRegistry writes all files of the given registry (including its internals, like metadata and trash) as a gzip
+// compressed tar archive. Symbolic links are archived as links (they are not followed).
+func ArchiveRegistry(w io.Writer, registry string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(registry, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("connot read %q: %w", path, err)
+		}
+		symlink := d.Type()&fs.ModeSymlink != 0
+		if !d.Type().IsRegular() && !symlink {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("connot read %q: %w", path, err)
+		}
+		rel, err := filepath.Rel(registry, path)
+		if err != nil {
+			return err
+		}
+		if symlink {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("cannot read symbolic link %q: %w", path, err)
+			}
+			h := &tar.Header{Name: filepath.ToSlash(rel), Linkname: target, Mode: 0777, ModTime: info.ModTime(), Typeflag: tar.TypeSymlink}
+			if err := tw.WriteHeader(h); err != nil {
+				return fmt.Errorf("cannot write archive: %w", err)
+			}
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read file %q: %w", path, err)
+		}
+		return writeTarFile(tw, filepath.ToSlash(rel), b, info.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("cannot write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("cannot write archive: %w", err)
+	}
+	return nil
+}
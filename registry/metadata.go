@@ -0,0 +1,251 @@
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MetadataFile is the name of the file (in the registry root directory) holding metadata of registry entries.
+const MetadataFile = ".metadata.yaml"
+
+// Metadata describes metadata of all entries in a single registry.
+type Metadata struct {
+	Locked  bool                      `yaml:"locked,omitempty"`
+	Entries map[string]*EntryMetadata `yaml:"entries,omitempty"`
+}
+
+// EntryMetadata describes metadata of a single registry entry.
+type EntryMetadata struct {
+	Labels    map[string]string `yaml:"labels,omitempty"`
+	Protected bool              `yaml:"protected,omitempty"`
+	Locked    bool              `yaml:"locked,omitempty"`
+	Expires   *time.Time        `yaml:"expires,omitempty"`
+}
+
+func (m *EntryMetadata) empty() bool {
+	return len(m.Labels) == 0 && !m.Protected && !m.Locked && m.Expires == nil
+}
+
+// Expired reports whether the entry has an expiry time, that has already passed.
+func (m *EntryMetadata) Expired(now time.Time) bool {
+	return m.Expires != nil && !now.Before(*m.Expires)
+}
+
+// Entry returns metadata of the entry with the given name (empty metadata, if the entry has none).
+func (m *Metadata) Entry(name string) *EntryMetadata {
+	if e, ok := m.Entries[name]; ok && e != nil {
+		return e
+	}
+	return &EntryMetadata{}
+}
+
+// SetEntry sets metadata of the entry with the given name. Empty metadata removes the entry from the set.
+func (m *Metadata) SetEntry(name string, e *EntryMetadata) {
+	if e == nil || e.empty() {
+		delete(m.Entries, name)
+		return
+	}
+	if m.Entries == nil {
+		m.Entries = map[string]*EntryMetadata{}
+	}
+	m.Entries[name] = e
+}
+
+// ReadMetadata reads metadata of all entries in the given registry. Missing metadata file results in empty metadata.
+func ReadMetadata(registry string) (*Metadata, error) {
+	path := filepath.Join(registry, MetadataFile)
+	m := &Metadata{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, fmt.Errorf("cannot read metadata file %q: %w", path, err)
+	}
+	if err := yaml.NewDecoder(bytes.NewReader(b)).Decode(m); err != nil && len(bytes.TrimSpace(b)) > 0 {
+		return nil, fmt.Errorf("cannot parse metadata file %q: %w", path, err)
+	}
+	return m, nil
+}
+
+// WriteMetadata writes metadata of all entries in the given registry.
+func WriteMetadata(registry string, m *Metadata) error {
+	path := filepath.Join(registry, MetadataFile)
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("cannot encode metadata: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("cannot encode metadata: %w", err)
+	}
+	return ForceWrite(path, buf)
+}
+
+// ErrLocked is returned (wrapped) when modifying a locked entry or an entry in a locked registry.
+var ErrLocked = errors.New("locked")
+
+// CheckWritable returns an error, when the given entry cannot be modified, because it is locked or its registry is
+// read-only or locked.
+func (r *Registries) CheckWritable(e Entry) error {
+	if e.Source.ReadOnly {
+		return fmt.Errorf("cannot modify entry %q, registry %q is read-only", e.Name, e.Source.Name)
+	}
+	m, err := r.sourceMetadata(e.Source)
+	if err != nil {
+		return err
+	}
+	if m.Locked {
+		return fmt.Errorf("cannot modify entry %q, registry %q is %w", e.Name, e.Source.Name, ErrLocked)
+	}
+	if m.Entry(e.Name).Locked {
+		return fmt.Errorf("cannot modify entry %q, it is %w", e.Name, ErrLocked)
+	}
+	return nil
+}
+
+// Write writes the given content to the entry, refusing to modify locked entries. Existing entries are overwritten only
+// when overwrite is set (and their previous content is moved to the trash).
+func (r *Registries) Write(e Entry, content io.Reader, overwrite bool) error {
+	if err := r.CheckWritable(e); err != nil {
+		return err
+	}
+	if err := Init(e.Source); err != nil {
+		return err
+	}
+	if overwrite {
+		if err := r.trash(e); err != nil {
+			return err
+		}
+		return ForceWrite(e.Path, content)
+	}
+	return Write(e.Path, content)
+}
+
+// Remove moves the given entry together with its metadata to the trash, refusing to remove locked entries.
+func (r *Registries) Remove(e Entry) error {
+	if err := r.CheckWritable(e); err != nil {
+		return err
+	}
+	if err := r.trash(e); err != nil {
+		return err
+	}
+	if err := Remove(e.Source.Path, e.Name); err != nil {
+		return err
+	}
+	m, err := ReadMetadata(e.Source.Path)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.Entries[e.Name]; !ok {
+		return nil
+	}
+	m.SetEntry(e.Name, nil)
+	if err := WriteMetadata(e.Source.Path, m); err != nil {
+		return err
+	}
+	r.meta[e.Source] = m
+	return nil
+}
+
+// Metadata returns metadata of the given entry.
+func (r *Registries) Metadata(e Entry) (*EntryMetadata, error) {
+	m, err := r.sourceMetadata(e.Source)
+	if err != nil {
+		return nil, err
+	}
+	return m.Entry(e.Name), nil
+}
+
+// SetMetadata sets metadata of the given entry, refusing to modify locked entries.
+func (r *Registries) SetMetadata(e Entry, em *EntryMetadata) error {
+	if err := r.CheckWritable(e); err != nil {
+		return err
+	}
+	return r.setMetadata(e, em)
+}
+
+// SetEntryLocked locks or unlocks the given entry. Unlike other metadata, the lock can be changed on locked entries (and
+// in locked registries).
+func (r *Registries) SetEntryLocked(e Entry, locked bool) error {
+	m, err := ReadMetadata(e.Source.Path)
+	if err != nil {
+		return err
+	}
+	em := *m.Entry(e.Name)
+	em.Locked = locked
+	return r.setMetadata(e, &em)
+}
+
+func (r *Registries) setMetadata(e Entry, em *EntryMetadata) error {
+	if e.Source.ReadOnly {
+		return fmt.Errorf("cannot modify entry %q, registry %q is read-only", e.Name, e.Source.Name)
+	}
+	m, err := ReadMetadata(e.Source.Path)
+	if err != nil {
+		return err
+	}
+	m.SetEntry(e.Name, em)
+	if err := WriteMetadata(e.Source.Path, m); err != nil {
+		return err
+	}
+	r.meta[e.Source] = m
+	return nil
+}
+
+// SetLocked locks or unlocks the entire registry.
+func (r *Registries) SetLocked(s *Source, locked bool) error {
+	if s.ReadOnly {
+		return fmt.Errorf("cannot modify registry %q, it is read-only", s.Name)
+	}
+	m, err := ReadMetadata(s.Path)
+	if err != nil {
+		return err
+	}
+	m.Locked = locked
+	if err := WriteMetadata(s.Path, m); err != nil {
+		return err
+	}
+	r.meta[s] = m
+	return nil
+}
+
+// Locked reports whether the entire registry is locked.
+func (r *Registries) Locked(s *Source) (bool, error) {
+	m, err := r.sourceMetadata(s)
+	if err != nil {
+		return false, err
+	}
+	return m.Locked, nil
+}
+
+func (r *Registries) sourceMetadata(s *Source) (*Metadata, error) {
+	if m, ok := r.meta[s]; ok {
+		return m, nil
+	}
+	m, err := ReadMetadata(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	r.meta[s] = m
+	return m, nil
+}
+
+// SortedLabels returns the given labels in the 'key=value' form, sorted by keys.
+func SortedLabels(labels map[string]string) []string {
+	res := make([]string, 0, len(labels))
+	for k, v := range labels {
+		res = append(res, k+"="+v)
+	}
+	sort.Strings(res)
+	return res
+}
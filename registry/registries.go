@@ -0,0 +1,282 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NameSeparator separates the registry name from the entry name in qualified entry names (e.g. 'team:aws/prod').
+const NameSeparator = ":"
+
+// Source describes a single named registry directory.
+type Source struct {
+	Name     string
+	Path     string
+	Priority int
+	ReadOnly bool
+}
+
+// Entry describes a single kubectl config file stored in a registry.
+type Entry struct {
+	Source *Source
+	Name   string
+	Path   string
+}
+
+// QualifiedName returns the entry name prefixed with the name of its registry.
+func (e Entry) QualifiedName() string {
+	return e.Source.Name + NameSeparator + e.Name
+}
+
+// Registries is a set of registries searched in the order of their priorities.
+type Registries struct {
+	sources     []*Source
+	write       *Source
+	meta        map[*Source]*Metadata
+	trashMaxAge time.Duration
+}
+
+// NewRegistries creates a new set of registries. Registries with higher priority are searched first (registries with
+// equal priorities are searched in the provided order). Writes go to the registry named by writeTo, or (if empty) to the
+// first writable registry in the search order.
+func NewRegistries(sources []*Source, writeTo string) (*Registries, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no registries configured")
+	}
+
+	seen := map[string]bool{}
+	for _, s := range sources {
+		if s.Name == "" {
+			return nil, fmt.Errorf("registry at %q has no name", s.Path)
+		}
+		if strings.ContainsAny(s.Name, NameSeparator+"/") {
+			return nil, fmt.Errorf("registry name %q cannot contain %q nor '/'", s.Name, NameSeparator)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("registry name %q is not unique", s.Name)
+		}
+		seen[s.Name] = true
+	}
+
+	r := &Registries{sources: append([]*Source(nil), sources...), meta: map[*Source]*Metadata{}}
+	sort.SliceStable(r.sources, func(i, j int) bool { return r.sources[i].Priority > r.sources[j].Priority })
+
+	if writeTo != "" {
+		s, ok := r.Source(writeTo)
+		if !ok {
+			return nil, fmt.Errorf("write registry %q is not configured", writeTo)
+		}
+		if s.ReadOnly {
+			return nil, fmt.Errorf("write registry %q is read-only", writeTo)
+		}
+		r.write = s
+	} else {
+		for _, s := range r.sources {
+			if !s.ReadOnly {
+				r.write = s
+				break
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// Sources returns all registries in the search order.
+func (r *Registries) Sources() []*Source {
+	return r.sources
+}
+
+// Source returns the registry with the given name.
+func (r *Registries) Source(name string) (*Source, bool) {
+	for _, s := range r.sources {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Writable returns the registry new entries are written to.
+func (r *Registries) Writable() (*Source, error) {
+	if r.write == nil {
+		return nil, fmt.Errorf("all configured registries are read-only")
+	}
+	return r.write, nil
+}
+
+// Multiple reports whether the set consists of more than one registry.
+func (r *Registries) Multiple() bool {
+	return len(r.sources) > 1
+}
+
+// SplitName splits the possibly qualified entry name into the registry and the entry name. The prefix is recognized only
+// when it names one of the registries.
+func (r *Registries) SplitName(name string) (*Source, string) {
+	if i := strings.Index(name, NameSeparator); i >= 0 {
+		if s, ok := r.Source(name[:i]); ok {
+			return s, name[i+len(NameSeparator):]
+		}
+	}
+	return nil, name
+}
+
+// Lookup resolves the (possibly qualified) name of an existing entry. Unqualified names are searched in the search
+// order.
+func (r *Registries) Lookup(name string) (Entry, error) {
+	s, n := r.SplitName(name)
+	if err := ValidateName(n); err != nil {
+		return Entry{}, err
+	}
+	sources := r.sources
+	if s != nil {
+		sources = []*Source{s}
+	}
+
+	for _, s := range sources {
+		exist, err := Exist(s.Path, n)
+		if err != nil {
+			return Entry{}, err
+		}
+		if exist {
+			return Entry{Source: s, Name: n, Path: NameToPath(s.Path, n)}, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("entry %q does not exist in the registry", name)
+}
+
+// Target resolves the (possibly qualified) name of an entry to be written. Unqualified names resolve to the write
+// registry.
+func (r *Registries) Target(name string) (Entry, error) {
+	s, n := r.SplitName(name)
+	if s == nil {
+		w, err := r.Writable()
+		if err != nil {
+			return Entry{}, err
+		}
+		s = w
+	}
+	if s.ReadOnly {
+		return Entry{}, fmt.Errorf("cannot write entry %q, registry %q is read-only", n, s.Name)
+	}
+	if err := ValidateName(n); err != nil {
+		return Entry{}, err
+	}
+	return Entry{Source: s, Name: n, Path: NameToPath(s.Path, n)}, nil
+}
+
+// ValidateName returns an error, when the given entry name cannot be stored in a registry (it is empty, its path
+// segments are empty, relative or it names files holding registry internals).
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty entry name")
+	}
+	for i, s := range strings.Split(name, "/") {
+		if s == "" || s == "." || s == ".." || strings.ContainsRune(s, '\\') {
+			return fmt.Errorf("invalid entry name %q, path segments cannot be empty nor '.' or '..'", name)
+		}
+		if internal(s, i == 0) {
+			return fmt.Errorf("invalid entry name %q, %q is reserved for registry internals", name, s)
+		}
+	}
+	return nil
+}
+
+// List returns all entries from all registries matching the given selector, sorted by name (entries with equal names
+// are sorted in the search order).
+func (r *Registries) List(sel Selector) ([]Entry, error) {
+	var res []Entry
+	for _, s := range r.sources {
+		ls, err := List(s.Path)
+		if err != nil {
+			return nil, err
+		}
+		m := &Metadata{}
+		if len(sel) > 0 {
+			if m, err = r.sourceMetadata(s); err != nil {
+				return nil, err
+			}
+		}
+		for _, path := range ls {
+			e := Entry{Source: s, Name: PathToName(s.Path, path), Path: path}
+			if sel.Matches(m.Entry(e.Name).Labels) {
+				res = append(res, e)
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// ListWithCmp returns all entries matching the given selector (see List) together with information which of them match
+// the given hash.
+func (r *Registries) ListWithCmp(hash []byte, sel Selector) ([]Entry, []bool, error) {
+	ls, err := r.List(sel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmp := make([]bool, 0, len(ls))
+	for _, e := range ls {
+		h, err := Hash(e.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		cmp = append(cmp, bytes.Equal(hash, h))
+	}
+
+	return ls, cmp, nil
+}
+
+// Find returns the first entry (in the search order) matching the given hash.
+func (r *Registries) Find(hash []byte) (Entry, bool, error) {
+	for _, s := range r.sources {
+		path, found, err := Find(s.Path, hash)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		if found {
+			return Entry{Source: s, Name: PathToName(s.Path, path), Path: path}, true, nil
+		}
+	}
+	return Entry{}, false, nil
+}
+
+// DisplayNames returns names of the given entries, qualified with the registry name, when the entry name alone is
+// ambiguous.
+func DisplayNames(entries []Entry) []string {
+	sources := map[string]map[*Source]bool{}
+	for _, e := range entries {
+		if sources[e.Name] == nil {
+			sources[e.Name] = map[*Source]bool{}
+		}
+		sources[e.Name][e.Source] = true
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if len(sources[e.Name]) > 1 {
+			names = append(names, e.QualifiedName())
+		} else {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+// DisplayName returns the name of the given entry, qualified with the registry name, when an entry with the same name
+// exists in any other registry.
+func (r *Registries) DisplayName(e Entry) string {
+	for _, s := range r.sources {
+		if s == e.Source {
+			continue
+		}
+		if exist, err := Exist(s.Path, e.Name); err == nil && exist {
+			return e.QualifiedName()
+		}
+	}
+	return e.Name
+}
@@ -12,6 +12,9 @@ import (
 	"golang.org/x/crypto/sha3"
 )
 
+// FormatVersion is the version of the registry format (layout of entries, metadata and trash) used by this build.
+const FormatVersion = 1
+
 func KubectlConfigPath() (string, error) {
 	home, ok := os.LookupEnv("HOME")
 	if !ok {
@@ -99,7 +102,7 @@ func Exist(registry string, name string) (bool, error) {
 	path := filepath.Join(registry, strings.ReplaceAll(name, "/", string(os.PathSeparator)))
 	stat, err := os.Stat(path)
 	if err != nil {
-		if err != os.ErrNotExist {
+		if !os.IsNotExist(err) {
 			return false, fmt.Errorf("cannot stat file %q: %w", path, err)
 		}
 		return false, nil
@@ -109,25 +112,28 @@ func Exist(registry string, name string) (bool, error) {
 
 func List(registry string) ([]string, error) {
 	var res []string
-	if err := listDirReclusive(registry, &res); err != nil {
+	if err := listDirReclusive(registry, registry, &res); err != nil {
 		return nil, err
 	}
 	sort.Strings(res)
 	return res, nil
 }
 
-func listDirReclusive(path string, result *[]string) error {
+func listDirReclusive(registry string, path string, result *[]string) error {
 	files, err := os.ReadDir(path)
 	if err != nil {
 		return fmt.Errorf("connot read directory %q: %w", path, err)
 	}
 
 	for _, file := range files {
+		if internal(file.Name(), path == registry) {
+			continue
+		}
 		switch {
 		case file.Type().IsRegular():
 			*result = append(*result, filepath.Join(path, file.Name()))
 		case file.IsDir():
-			if err := listDirReclusive(filepath.Join(path, file.Name()), result); err != nil {
+			if err := listDirReclusive(registry, filepath.Join(path, file.Name()), result); err != nil {
 				return err
 			}
 		}
@@ -136,6 +142,18 @@ func listDirReclusive(path string, result *[]string) error {
 	return nil
 }
 
+// tempPrefix starts names of temporary files written by ForceWrite (left behind only by interrupted writes).
+const tempPrefix = ".tmp-"
+
+// internal reports whether the file or directory with the given name holds registry internals rather than entries. Root
+// tells, whether it is stored directly in the registry root directory.
+func internal(name string, root bool) bool {
+	if strings.HasPrefix(name, tempPrefix) {
+		return true
+	}
+	return root && (name == MetadataFile || name == ManifestFile || name == TrashDir)
+}
+
 func ListWithCmp(registry string, hash []byte) ([]string, []bool, error) {
 	ls, err := List(registry)
 	if err != nil {
@@ -167,8 +185,62 @@ func Write(path string, content io.Reader) error {
 	return writeWithFlag(path, content, os.O_RDWR|os.O_CREATE|os.O_EXCL)
 }
 
+// ForceWrite writes the content to the file under the given path, replacing it atomically (the content is written to a
+// temporary file, which is then renamed). Symbolic links are followed, so that the file they point to is replaced.
 func ForceWrite(path string, content io.Reader) error {
-	return writeWithFlag(path, content, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory %q for file %q: %w", dir, path, err)
+	}
+
+	f, err := os.CreateTemp(dir, tempPrefix+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("cannot create file %q: %w", path, err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op after a successful rename
+
+	mode := os.FileMode(0640)
+	if stat, err := os.Stat(path); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot set mode of file %q: %w", tmp, err)
+	}
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		return fmt.Errorf("writing to file %q filed: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("writing to file %q filed: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing to file %q filed: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("cannot replace file %q: %w", path, err)
+	}
+	return nil
+}
+
+// Remove removes the entry with the given name from the registry, together with directories left empty.
+func Remove(registry string, name string) error {
+	path := NameToPath(registry, name)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("cannot remove file %q: %w", path, err)
+	}
+	root := filepath.Clean(registry)
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break // not empty
+		}
+	}
+	return nil
 }
 
 func writeWithFlag(path string, content io.Reader, flag int) error {
@@ -0,0 +1,122 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Selector operators.
+const (
+	OpEquals    = "="
+	OpNotEquals = "!="
+	OpExists    = "exists"
+	OpNotExists = "!exists"
+)
+
+var (
+	labelKeyRegexp   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+	labelValueRegexp = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?)?$`)
+)
+
+// Requirement describes a single label requirement of a selector.
+type Requirement struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+// Matches reports whether the given labels fulfill the requirement.
+func (r Requirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	switch r.Op {
+	case OpEquals:
+		return ok && v == r.Value
+	case OpNotEquals:
+		return !ok || v != r.Value
+	case OpExists:
+		return ok
+	case OpNotExists:
+		return !ok
+	}
+	return false
+}
+
+// Selector is a set of label requirements that all must be fulfilled.
+type Selector []Requirement
+
+// Matches reports whether the given labels fulfill all requirements of the selector. Empty selector matches everything.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSelector parses comma separated list of label requirements in one of the forms: 'key=value', 'key==value',
+// 'key!=value', 'key' (label exists) and '!key' (label does not exist).
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		r := Requirement{}
+		switch {
+		case strings.Contains(part, "!="):
+			kv := strings.SplitN(part, "!=", 2)
+			r = Requirement{Key: kv[0], Op: OpNotEquals, Value: kv[1]}
+		case strings.Contains(part, "=="):
+			kv := strings.SplitN(part, "==", 2)
+			r = Requirement{Key: kv[0], Op: OpEquals, Value: kv[1]}
+		case strings.Contains(part, "="):
+			kv := strings.SplitN(part, "=", 2)
+			r = Requirement{Key: kv[0], Op: OpEquals, Value: kv[1]}
+		case strings.HasPrefix(part, "!"):
+			r = Requirement{Key: part[1:], Op: OpNotExists}
+		default:
+			r = Requirement{Key: part, Op: OpExists}
+		}
+
+		r.Key, r.Value = strings.TrimSpace(r.Key), strings.TrimSpace(r.Value)
+		if err := ValidateLabel(r.Key, r.Value); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// ValidateLabel checks whether the given label key and value are well formed.
+func ValidateLabel(key string, value string) error {
+	if !labelKeyRegexp.MatchString(key) {
+		return fmt.Errorf("invalid label key %q", key)
+	}
+	if !labelValueRegexp.MatchString(value) {
+		return fmt.Errorf("invalid value %q of label %q", value, key)
+	}
+	return nil
+}
+
+// MatchGlob reports whether the entry name matches the given glob pattern. Unlike in file path patterns, '*' matches any
+// sequence of characters (including '/') and '?' matches any single character.
+func MatchGlob(pattern string, name string) bool {
+	re := strings.Builder{}
+	re.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.MustCompile(re.String()).MatchString(name)
+}
@@ -0,0 +1,188 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrashDir is the name of the directory (in the registry root directory) holding removed and overwritten entries.
+const TrashDir = ".trash"
+
+// TrashItem describes a single removed or overwritten entry stored in the trash of a registry.
+type TrashItem struct {
+	Source   *Source
+	ID       string
+	Name     string
+	Time     time.Time
+	Path     string
+	Metadata *EntryMetadata
+}
+
+// QualifiedID returns the item ID prefixed with the name of its registry.
+func (t TrashItem) QualifiedID() string {
+	return t.Source.Name + NameSeparator + t.ID
+}
+
+// SetTrashMaxAge sets the age after which items in the trash are purged (zero disables purging).
+func (r *Registries) SetTrashMaxAge(d time.Duration) {
+	r.trashMaxAge = d
+}
+
+// trash stores the current content of the given entry (together with its metadata) in the trash of its registry. Each
+// item is kept in its own directory, named by the time of removal, laid out as a tiny registry.
+func (r *Registries) trash(e Entry) error {
+	f, err := os.Open(e.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot open file %q: %w", e.Path, err)
+	}
+	defer f.Close()
+
+	m, err := r.sourceMetadata(e.Source)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(e.Source.Path, TrashDir, time.Now().UTC().Format(backupTimeFormat))
+	if err := writeWithFlag(NameToPath(dir, e.Name), f, os.O_RDWR|os.O_CREATE|os.O_EXCL); err != nil {
+		return fmt.Errorf("moving entry %q to trash filed: %w", e.Name, err)
+	}
+	tm := &Metadata{}
+	tm.SetEntry(e.Name, m.Entry(e.Name))
+	if len(tm.Entries) > 0 {
+		if err := WriteMetadata(dir, tm); err != nil {
+			return err
+		}
+	}
+
+	if r.trashMaxAge > 0 {
+		_, err := PurgeTrash(e.Source, time.Now().Add(-r.trashMaxAge))
+		return err
+	}
+	return nil
+}
+
+// ListTrash returns items in the trash of the given registry, from the newest to the oldest.
+func ListTrash(s *Source) ([]TrashItem, error) {
+	root := filepath.Join(s.Path, TrashDir)
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("connot read directory %q: %w", root, err)
+	}
+
+	items := []TrashItem{}
+	for _, d := range dirs {
+		t, err := time.Parse(backupTimeFormat, d.Name())
+		if err != nil || !d.IsDir() {
+			continue // not a trash item
+		}
+		dir := filepath.Join(root, d.Name())
+		names, err := List(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			continue
+		}
+		m, err := ReadMetadata(dir)
+		if err != nil {
+			return nil, err
+		}
+		name := PathToName(dir, names[0])
+		items = append(items, TrashItem{Source: s, ID: d.Name(), Name: name, Time: t, Path: names[0], Metadata: m.Entry(name)})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Time.After(items[j].Time) })
+	return items, nil
+}
+
+// Trash returns items in the trash of all registries, from the newest to the oldest.
+func (r *Registries) Trash() ([]TrashItem, error) {
+	items := []TrashItem{}
+	for _, s := range r.sources {
+		ls, err := ListTrash(s)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, ls...)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Time.After(items[j].Time) })
+	return items, nil
+}
+
+// FindTrash returns the trash item with the given (optionally qualified) ID or, if there is no such item, the newest
+// item with the given entry name.
+func (r *Registries) FindTrash(idOrName string) (TrashItem, error) {
+	items, err := r.Trash()
+	if err != nil {
+		return TrashItem{}, err
+	}
+	s, name := r.SplitName(idOrName)
+	for _, match := range []func(t TrashItem) bool{
+		func(t TrashItem) bool { return t.ID == name },
+		func(t TrashItem) bool { return t.Name == name },
+	} {
+		for _, t := range items {
+			if (s == nil || t.Source == s) && match(t) {
+				return t, nil
+			}
+		}
+	}
+	return TrashItem{}, fmt.Errorf("there is no %q in the trash", idOrName)
+}
+
+// Restore restores the given trash item as the given entry (together with its metadata) and removes it from the trash.
+// Existing entries are overwritten (and moved to the trash) only when overwrite is set.
+func (r *Registries) Restore(t TrashItem, e Entry, overwrite bool) error {
+	b, err := os.ReadFile(t.Path)
+	if err != nil {
+		return fmt.Errorf("cannot read file %q: %w", t.Path, err)
+	}
+	if err := r.Write(e, bytes.NewReader(b), overwrite); err != nil {
+		return err
+	}
+	if err := r.SetMetadata(e, t.Metadata); err != nil {
+		return err
+	}
+	return removeTrashItem(t)
+}
+
+// PurgeTrash permanently removes items moved to the trash of the given registry before the given time and returns the
+// number of removed items.
+func PurgeTrash(s *Source, before time.Time) (int, error) {
+	items, err := ListTrash(s)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, t := range items {
+		if !t.Time.Before(before) {
+			continue
+		}
+		if err := removeTrashItem(t); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func removeTrashItem(t TrashItem) error {
+	dir := filepath.Join(t.Source.Path, TrashDir, t.ID)
+	if !strings.HasPrefix(t.Path, dir) {
+		return fmt.Errorf("invalid trash item %q", t.ID)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("cannot remove directory %q: %w", dir, err)
+	}
+	return nil
+}
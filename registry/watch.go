@@ -0,0 +1,289 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventType describes the kind of change reported by Watch.
+type EventType string
+
+// Kinds of changes reported by Watch.
+const (
+	EventSwitched       EventType = "switched"        // the active kubectl config now matches another entry
+	EventActiveModified EventType = "active-modified" // the active kubectl config changed and matches no entry
+	EventActiveRemoved  EventType = "active-removed"  // the active kubectl config was removed
+	EventAdded          EventType = "added"           // a new entry appeared in a registry
+	EventRemoved        EventType = "removed"         // an entry was removed from a registry
+	EventModified       EventType = "modified"        // content of an entry changed
+	EventError          EventType = "error"           // watching failed (the watch continues)
+)
+
+// watchDebounce is the time, during which file system notifications are collected before the state is compared.
+// Writing a single file usually results in several notifications.
+const watchDebounce = 100 * time.Millisecond
+
+// Event describes a single change of the active kubectl config or registry entries.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Entry is the entry the change concerns (for EventSwitched, the entry matching the active kubectl config).
+	Entry Entry
+	// Previous is the entry the active kubectl config matched before switching (nil, if it matched none).
+	Previous *Entry
+	// Err is the problem encountered (for EventError only).
+	Err error
+}
+
+// Watch reports changes of the active kubectl config (under the given path) and of entries in all registries, until the
+// given context is done (and the returned channel is closed). Changes are detected with file system notifications
+// (e.g. inotify), so no polling is involved. Directories that do not exist yet (e.g. a registry before the first
+// entry is saved) are watched through their nearest existing parent and added as soon as they appear.
+func (r *Registries) Watch(ctx context.Context, kubectlConfig string) (<-chan Event, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create file system watcher: %w", err)
+	}
+	kcDir := filepath.Dir(kubectlConfig)
+	if err := r.watchDirs(w, kcDir); err != nil {
+		w.Close()
+		return nil, err
+	}
+	prev, err := r.snapshot(kubectlConfig)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	events := make(chan Event)
+	send := func(e Event) bool {
+		e.Time = time.Now()
+		select {
+		case events <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(events)
+		defer w.Close()
+
+		var settle <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Name != kubectlConfig && !r.inRegistry(ev.Name) && !r.onWatchedPath(ev.Name, kcDir) {
+					continue // other files next to the kubectl config (e.g. kubectl cache) or in parents of missing directories
+				}
+				if settle == nil {
+					settle = time.After(watchDebounce)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				if !send(Event{Type: EventError, Err: err}) {
+					return
+				}
+			case <-settle:
+				settle = nil
+				if err := r.watchDirs(w, kcDir); err != nil && !send(Event{Type: EventError, Err: err}) {
+					return
+				}
+				next, err := r.snapshot(kubectlConfig)
+				if err != nil {
+					if !send(Event{Type: EventError, Err: err}) {
+						return
+					}
+					continue
+				}
+				for _, e := range prev.diff(next) {
+					if !send(e) {
+						return
+					}
+				}
+				prev = next
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// watchDirs adds the directory of the kubectl config and all registry directories (except ones holding registry
+// internals) to the watcher. Adding an already watched directory is a no-op, so it is called again after changes to
+// pick up new (sub)directories.
+func (r *Registries) watchDirs(w *fsnotify.Watcher, kcDir string) error {
+	if err := watchNearest(w, kcDir); err != nil {
+		return err
+	}
+	for _, s := range r.sources {
+		if err := watchNearest(w, s.Path); err != nil {
+			return err
+		}
+		err := filepath.WalkDir(s.Path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil // removed in the meantime
+				}
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if path != s.Path && internal(d.Name(), filepath.Dir(path) == s.Path) {
+				return filepath.SkipDir
+			}
+			if err := w.Add(path); err != nil {
+				return fmt.Errorf("cannot watch directory %q: %w", path, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchNearest adds the given directory to the watcher or, when it does not exist, its nearest existing parent, so that
+// creating it is noticed.
+func watchNearest(w *fsnotify.Watcher, dir string) error {
+	for {
+		err := w.Add(dir)
+		if err == nil {
+			return nil
+		}
+		parent := filepath.Dir(dir)
+		if !errors.Is(err, fs.ErrNotExist) || parent == dir {
+			return fmt.Errorf("cannot watch directory %q: %w", dir, err)
+		}
+		dir = parent
+	}
+}
+
+// onWatchedPath reports whether the given path is the directory of the kubectl config, a registry directory or one of
+// their parents (so that creating it matters to Watch).
+func (r *Registries) onWatchedPath(path string, kcDir string) bool {
+	within := func(dir string) bool {
+		rel, err := filepath.Rel(path, dir)
+		return err == nil && !strings.HasPrefix(rel, "..")
+	}
+	if within(kcDir) {
+		return true
+	}
+	for _, s := range r.sources {
+		if within(s.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Registries) inRegistry(path string) bool {
+	for _, s := range r.sources {
+		if rel, err := filepath.Rel(s.Path, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// watchSnapshot describes the state compared by Watch.
+type watchSnapshot struct {
+	entries []Entry
+	hashes  map[string][]byte // by qualified names
+	active  []byte            // nil, when the kubectl config does not exist
+	current *Entry
+}
+
+func (r *Registries) snapshot(kubectlConfig string) (*watchSnapshot, error) {
+	s := &watchSnapshot{hashes: map[string][]byte{}}
+	for _, src := range r.sources {
+		ls, err := List(src.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // not created yet, watched through its parent
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range ls {
+			e := Entry{Source: src, Name: PathToName(src.Path, path), Path: path}
+			h, err := Hash(e.Path)
+			if err != nil {
+				continue // removed in the meantime, reported by the next snapshot
+			}
+			s.entries = append(s.entries, e)
+			s.hashes[e.QualifiedName()] = h
+		}
+	}
+
+	if _, err := os.Stat(kubectlConfig); errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	var err error
+	if s.active, err = Hash(kubectlConfig); err != nil {
+		return nil, err
+	}
+	for _, src := range r.sources { // first match in the search order, as Find does
+		for _, e := range s.entries {
+			if e.Source == src && bytes.Equal(s.hashes[e.QualifiedName()], s.active) {
+				e := e
+				s.current = &e
+				break
+			}
+		}
+		if s.current != nil {
+			break
+		}
+	}
+	return s, nil
+}
+
+// diff returns events describing changes between the snapshots.
+func (s *watchSnapshot) diff(next *watchSnapshot) []Event {
+	var res []Event
+	for _, e := range next.entries {
+		h, ok := s.hashes[e.QualifiedName()]
+		switch {
+		case !ok:
+			res = append(res, Event{Type: EventAdded, Entry: e})
+		case !bytes.Equal(h, next.hashes[e.QualifiedName()]):
+			res = append(res, Event{Type: EventModified, Entry: e})
+		}
+	}
+	for _, e := range s.entries {
+		if _, ok := next.hashes[e.QualifiedName()]; !ok {
+			res = append(res, Event{Type: EventRemoved, Entry: e})
+		}
+	}
+
+	if bytes.Equal(s.active, next.active) {
+		return res
+	}
+	switch {
+	case next.active == nil:
+		res = append(res, Event{Type: EventActiveRemoved, Previous: s.current})
+	case next.current == nil:
+		res = append(res, Event{Type: EventActiveModified, Previous: s.current})
+	case s.current == nil || s.current.QualifiedName() != next.current.QualifiedName():
+		res = append(res, Event{Type: EventSwitched, Entry: *next.current, Previous: s.current})
+	}
+	return res
+}
@@ -0,0 +1,528 @@
+package settings
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of environment variables overriding values from the settings file.
+const EnvPrefix = "KUBECONFIG_"
+
+// Output formats.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// Confirmation policies.
+const (
+	ConfirmForce  = "force"  // require the '--force' flag
+	ConfirmPrompt = "prompt" // ask interactively (and fall back to requiring the '--force' flag, when not possible)
+	ConfirmNone   = "none"   // proceed without asking
+)
+
+// Colors recognized in color related settings.
+var Colors = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// Settings describes the kubeconfig tool configuration.
+type Settings struct {
+	Registry      string             `yaml:"registry,omitempty"`
+	Registries    []RegistrySettings `yaml:"registries,omitempty"`
+	WriteRegistry string             `yaml:"writeRegistry,omitempty"`
+	KubectlConfig string             `yaml:"kubectlConfig,omitempty"`
+	Editor        string             `yaml:"editor,omitempty"`
+	Output        string             `yaml:"output,omitempty"`
+	Colors        ColorSettings      `yaml:"colors,omitempty"`
+	Backup        BackupSettings     `yaml:"backup,omitempty"`
+	Confirm       ConfirmSettings    `yaml:"confirm,omitempty"`
+	Protection    ProtectionSettings `yaml:"protection,omitempty"`
+	Trash         TrashSettings      `yaml:"trash,omitempty"`
+	Hooks         HookSettings       `yaml:"hooks,omitempty"`
+}
+
+// RegistrySettings describes a single named registry.
+type RegistrySettings struct {
+	Name     string `yaml:"name"`
+	Path     string `yaml:"path"`
+	Priority int    `yaml:"priority,omitempty"`
+	ReadOnly bool   `yaml:"readOnly,omitempty"`
+}
+
+// ColorSettings describes colors used by the interactive prompts.
+type ColorSettings struct {
+	Active  string `yaml:"active,omitempty"`
+	Current string `yaml:"current,omitempty"`
+}
+
+// BackupSettings describes how backups are kept.
+type BackupSettings struct {
+	Keep *int `yaml:"keep,omitempty"`
+}
+
+// ConfirmSettings describes confirmation policies of potentially destructive operations.
+type ConfirmSettings struct {
+	SwitchUnknown string `yaml:"switchUnknown,omitempty"`
+	Overwrite     string `yaml:"overwrite,omitempty"`
+}
+
+// ProtectionSettings describes which entries are protected and how switching to them is guarded.
+type ProtectionSettings struct {
+	Patterns    string `yaml:"patterns,omitempty"`
+	Selector    string `yaml:"selector,omitempty"`
+	SafeEntry   string `yaml:"safeEntry,omitempty"`
+	RevertAfter string `yaml:"revertAfter,omitempty"`
+}
+
+// TrashSettings describes how long removed and overwritten entries are kept.
+type TrashSettings struct {
+	MaxAge string `yaml:"maxAge,omitempty"`
+}
+
+// HookSettings describes how hooks are executed.
+type HookSettings struct {
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// Key describes a single setting.
+type Key struct {
+	Name    string
+	Usage   string
+	Default string
+
+	get      func(s *Settings) string
+	set      func(s *Settings, v string)
+	validate func(v string) error
+}
+
+// Env returns the name of the environment variable overriding the setting.
+func (k *Key) Env() string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(k.Name))
+}
+
+// Keys returns descriptions of all known settings.
+func Keys() []*Key {
+	return keys
+}
+
+// LookupKey returns description of the setting with the given name.
+func LookupKey(name string) (*Key, error) {
+	for _, k := range keys {
+		if k.Name == name {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown setting %q", name)
+}
+
+var keys = []*Key{
+	{
+		Name:  "registry",
+		Usage: "path to the kubeconfig registry directory (when no named registries are configured)",
+		get:   func(s *Settings) string { return s.Registry },
+		set:   func(s *Settings, v string) { s.Registry = v },
+	},
+	{
+		Name:  "write-registry",
+		Usage: "name of the registry new entries are written to (when multiple registries are configured)",
+		get:   func(s *Settings) string { return s.WriteRegistry },
+		set:   func(s *Settings, v string) { s.WriteRegistry = v },
+	},
+	{
+		Name:  "kubectl-config",
+		Usage: "path to the kubectl config file managed by kubeconfig",
+		get:   func(s *Settings) string { return s.KubectlConfig },
+		set:   func(s *Settings, v string) { s.KubectlConfig = v },
+	},
+	{
+		Name:  "editor",
+		Usage: "editor used to edit kubectl config files (defaults to the ${EDITOR} environment variable)",
+		get:   func(s *Settings) string { return s.Editor },
+		set:   func(s *Settings, v string) { s.Editor = v },
+	},
+	{
+		Name:     "output",
+		Usage:    "default output format (text, json or yaml)",
+		Default:  OutputText,
+		get:      func(s *Settings) string { return s.Output },
+		set:      func(s *Settings, v string) { s.Output = v },
+		validate: oneOf(OutputText, OutputJSON, OutputYAML),
+	},
+	{
+		Name:     "colors.active",
+		Usage:    "color of the highlighted entry in interactive prompts",
+		Default:  "cyan",
+		get:      func(s *Settings) string { return s.Colors.Active },
+		set:      func(s *Settings, v string) { s.Colors.Active = v },
+		validate: oneOf(Colors...),
+	},
+	{
+		Name:     "colors.current",
+		Usage:    "color of the current entry marker",
+		Default:  "green",
+		get:      func(s *Settings) string { return s.Colors.Current },
+		set:      func(s *Settings, v string) { s.Colors.Current = v },
+		validate: oneOf(Colors...),
+	},
+	{
+		Name:    "backup.keep",
+		Usage:   "number of backups retained for every backed up file (0 disables backups)",
+		Default: "10",
+		get: func(s *Settings) string {
+			if s.Backup.Keep == nil {
+				return ""
+			}
+			return strconv.Itoa(*s.Backup.Keep)
+		},
+		set: func(s *Settings, v string) {
+			if v == "" {
+				s.Backup.Keep = nil
+				return
+			}
+			n, _ := strconv.Atoi(v)
+			s.Backup.Keep = &n
+		},
+		validate: nonNegativeInt,
+	},
+	{
+		Name:     "confirm.switch-unknown",
+		Usage:    "policy of overriding kubectl config file not known to the registry (force, prompt or none)",
+		Default:  ConfirmForce,
+		get:      func(s *Settings) string { return s.Confirm.SwitchUnknown },
+		set:      func(s *Settings, v string) { s.Confirm.SwitchUnknown = v },
+		validate: oneOf(ConfirmForce, ConfirmPrompt, ConfirmNone),
+	},
+	{
+		Name:     "confirm.overwrite",
+		Usage:    "policy of overwriting existing registry entries (force, prompt or none)",
+		Default:  ConfirmForce,
+		get:      func(s *Settings) string { return s.Confirm.Overwrite },
+		set:      func(s *Settings, v string) { s.Confirm.Overwrite = v },
+		validate: oneOf(ConfirmForce, ConfirmPrompt, ConfirmNone),
+	},
+	{
+		Name:  "protection.patterns",
+		Usage: "comma separated list of glob patterns of names of protected entries (e.g. '*prod*', '*' matches also '/')",
+		get:   func(s *Settings) string { return s.Protection.Patterns },
+		set:   func(s *Settings, v string) { s.Protection.Patterns = v },
+	},
+	{
+		Name:  "protection.selector",
+		Usage: "label selector of protected entries (e.g. 'env=prod')",
+		get:   func(s *Settings) string { return s.Protection.Selector },
+		set:   func(s *Settings, v string) { s.Protection.Selector = v },
+	},
+	{
+		Name:  "protection.safe-entry",
+		Usage: "entry switched back to after the protection.revert-after timeout",
+		get:   func(s *Settings) string { return s.Protection.SafeEntry },
+		set:   func(s *Settings, v string) { s.Protection.SafeEntry = v },
+	},
+	{
+		Name:     "protection.revert-after",
+		Usage:    "duration after which a protected entry is automatically switched to the protection.safe-entry (0 disables)",
+		Default:  "0",
+		get:      func(s *Settings) string { return s.Protection.RevertAfter },
+		set:      func(s *Settings, v string) { s.Protection.RevertAfter = v },
+		validate: nonNegativeDuration,
+	},
+	{
+		Name:     "trash.max-age",
+		Usage:    "duration after which removed and overwritten entries are purged from the trash (0 keeps them forever)",
+		Default:  "720h",
+		get:      func(s *Settings) string { return s.Trash.MaxAge },
+		set:      func(s *Settings, v string) { s.Trash.MaxAge = v },
+		validate: nonNegativeDuration,
+	},
+	{
+		Name:     "hooks.timeout",
+		Usage:    "duration after which a running hook is killed (0 disables)",
+		Default:  "30s",
+		get:      func(s *Settings) string { return s.Hooks.Timeout },
+		set:      func(s *Settings, v string) { s.Hooks.Timeout = v },
+		validate: nonNegativeDuration,
+	},
+}
+
+// ProtectionPatterns returns glob patterns of names of protected entries.
+func (s *Settings) ProtectionPatterns() []string {
+	var res []string
+	for _, p := range strings.Split(s.Protection.Patterns, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// RevertAfter returns the duration after which a protected entry is automatically reverted (zero, if disabled).
+func (s *Settings) RevertAfter() time.Duration {
+	d, _ := time.ParseDuration(s.Value("protection.revert-after"))
+	return d
+}
+
+// TrashMaxAge returns the duration after which items in the trash are purged (zero, if disabled).
+func (s *Settings) TrashMaxAge() time.Duration {
+	d, _ := time.ParseDuration(s.Value("trash.max-age"))
+	return d
+}
+
+// HookTimeout returns the duration after which a running hook is killed (zero, if disabled).
+func (s *Settings) HookTimeout() time.Duration {
+	d, _ := time.ParseDuration(s.Value("hooks.timeout"))
+	return d
+}
+
+func oneOf(allowed ...string) func(v string) error {
+	return func(v string) error {
+		for _, a := range allowed {
+			if v == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid value %q, expected one of: %s", v, strings.Join(allowed, ", "))
+	}
+}
+
+func nonNegativeDuration(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid value %q, expected non-negative duration (e.g. '15m')", v)
+	}
+	return nil
+}
+
+func nonNegativeInt(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid value %q, expected non-negative integer", v)
+	}
+	return nil
+}
+
+// Get returns the value of the setting with the given name, as stored in the settings (without defaults).
+func (s *Settings) Get(name string) (string, error) {
+	k, err := LookupKey(name)
+	if err != nil {
+		return "", err
+	}
+	return k.get(s), nil
+}
+
+// Set validates and sets the value of the setting with the given name. Empty value resets the setting to its default.
+func (s *Settings) Set(name string, value string) error {
+	k, err := LookupKey(name)
+	if err != nil {
+		return err
+	}
+	if value != "" && k.validate != nil {
+		if err := k.validate(value); err != nil {
+			return fmt.Errorf("setting %q: %w", name, err)
+		}
+	}
+	k.set(s, value)
+	return nil
+}
+
+// Value returns the value of the setting with the given name, falling back to its default.
+func (s *Settings) Value(name string) string {
+	k, err := LookupKey(name)
+	if err != nil {
+		return ""
+	}
+	if v := k.get(s); v != "" {
+		return v
+	}
+	return k.Default
+}
+
+// BackupKeep returns the number of retained backups.
+func (s *Settings) BackupKeep() int {
+	n, _ := strconv.Atoi(s.Value("backup.keep"))
+	return n
+}
+
+// Dir returns the directory holding the kubeconfig tool configuration.
+func Dir() (string, error) {
+	if dir, ok := os.LookupEnv("XDG_CONFIG_HOME"); ok && dir != "" {
+		return filepath.Join(dir, "kubeconfig"), nil
+	}
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot obtain the user configuration directory: %w", err)
+		}
+		return filepath.Join(dir, "kubeconfig"), nil
+	}
+	return homeSubdir(".config", "kubeconfig")
+}
+
+// StateDir returns the directory holding the kubeconfig tool state (backups etc).
+func StateDir() (string, error) {
+	if dir, ok := os.LookupEnv("XDG_STATE_HOME"); ok && dir != "" {
+		return filepath.Join(dir, "kubeconfig"), nil
+	}
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot obtain the user state directory: %w", err)
+		}
+		return filepath.Join(dir, "kubeconfig"), nil
+	}
+	return homeSubdir(".local", "state", "kubeconfig")
+}
+
+// BackupDir returns the directory holding backups.
+func BackupDir() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
+// HooksDir returns the directory holding hooks.
+func HooksDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hooks"), nil
+}
+
+// Path returns the path to the settings file.
+func Path() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+func homeSubdir(elem ...string) (string, error) {
+	home, ok := os.LookupEnv("HOME")
+	if !ok {
+		return "", fmt.Errorf("$HOME environment vatiabble not set")
+	}
+	path, err := filepath.Abs(home)
+	if err != nil {
+		return "", fmt.Errorf("cannot obtain the absolute path to the user home directory: %w", err)
+	}
+	return filepath.Join(append([]string{path}, elem...)...), nil
+}
+
+// ExpandPath expands the leading '~' in the given path to the user home directory.
+func ExpandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(os.PathSeparator)) {
+		return path, nil
+	}
+	home, err := homeSubdir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// ReadFile reads settings from the given file. Missing file results in empty settings.
+func ReadFile(path string) (*Settings, error) {
+	s := &Settings{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("cannot read settings file %q: %w", path, err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("cannot parse settings file %q: %w", path, err)
+	}
+	for _, k := range keys {
+		if v := k.get(s); v != "" && k.validate != nil {
+			if err := k.validate(v); err != nil {
+				return nil, fmt.Errorf("settings file %q: setting %q: %w", path, k.Name, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// WriteFile writes settings to the given file.
+func WriteFile(path string, s *Settings) error {
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("cannot encode settings: %w", err)
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory %q for file %q: %w", dir, path, err)
+	}
+	if err := os.WriteFile(path, b, 0640); err != nil {
+		return fmt.Errorf("cannot write settings file %q: %w", path, err)
+	}
+	return nil
+}
+
+// Source describes where the effective value of a setting comes from.
+type Source string
+
+// Setting sources.
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+)
+
+// Load reads the settings file and applies overrides from environment variables.
+func Load() (*Settings, map[string]Source, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sources := make(map[string]Source, len(keys))
+	for _, k := range keys {
+		sources[k.Name] = SourceDefault
+		if k.get(s) != "" {
+			sources[k.Name] = SourceFile
+		}
+		v, ok := os.LookupEnv(k.Env())
+		if !ok || v == "" {
+			continue
+		}
+		if k.validate != nil {
+			if err := k.validate(v); err != nil {
+				return nil, nil, fmt.Errorf("environment variable %s: %w", k.Env(), err)
+			}
+		}
+		k.set(s, v)
+		sources[k.Name] = SourceEnv
+	}
+	return s, sources, nil
+}
+
+// Names returns sorted names of all known settings.
+func Names() []string {
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.Name)
+	}
+	sort.Strings(names)
+	return names
+}
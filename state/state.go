@@ -0,0 +1,218 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/daishe/kubeconfig/settings"
+)
+
+// State describes the kubeconfig tool state, that is not part of any registry (usage statistics, favourites etc).
+// Entries are identified by their qualified names.
+type State struct {
+	Usage            map[string]*Usage `yaml:"usage,omitempty"`
+	Pinned           []string          `yaml:"pinned,omitempty"`
+	Allowed          map[string]string `yaml:"allowed,omitempty"`
+	AllowedProtected []string          `yaml:"allowedProtected,omitempty"`
+
+	path string
+}
+
+// Usage describes how the given entry was used.
+type Usage struct {
+	LastUsed time.Time `yaml:"lastUsed"`
+	Count    int       `yaml:"count"`
+}
+
+// Path returns the path to the state file.
+func Path() (string, error) {
+	dir, err := settings.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.yaml"), nil
+}
+
+// Load reads the state file. Missing file results in empty state.
+func Load() (*State, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &State{path: path}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("cannot read state file %q: %w", path, err)
+	}
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("cannot parse state file %q: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save writes the state file.
+func (s *State) Save() error {
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(s); err != nil {
+		return fmt.Errorf("cannot encode state: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("cannot encode state: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory %q for file %q: %w", dir, s.path, err)
+	}
+	tmp, err := os.CreateTemp(dir, ".state-*.yaml")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing to file %q filed: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing file %q filed: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("cannot replace state file %q: %w", s.path, err)
+	}
+	return nil
+}
+
+// Update loads the state, applies the given modification and saves the result.
+func Update(modify func(s *State) error) error {
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+	if err := modify(s); err != nil {
+		return err
+	}
+	return s.Save()
+}
+
+// Used records the use of the entry with the given name.
+func (s *State) Used(name string, t time.Time) {
+	if s.Usage == nil {
+		s.Usage = map[string]*Usage{}
+	}
+	u, ok := s.Usage[name]
+	if !ok || u == nil {
+		u = &Usage{}
+		s.Usage[name] = u
+	}
+	u.LastUsed = t
+	u.Count++
+}
+
+// UsageOf returns usage of the entry with the given name (empty, if the entry was never used).
+func (s *State) UsageOf(name string) Usage {
+	if u, ok := s.Usage[name]; ok && u != nil {
+		return *u
+	}
+	return Usage{}
+}
+
+// PinIndex returns the position of the entry with the given name on the favourites list, or -1 if not pinned.
+func (s *State) PinIndex(name string) int {
+	for i, p := range s.Pinned {
+		if p == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Pin adds the entry with the given name to the end of the favourites list. It reports false, if already pinned.
+func (s *State) Pin(name string) bool {
+	if s.PinIndex(name) >= 0 {
+		return false
+	}
+	s.Pinned = append(s.Pinned, name)
+	return true
+}
+
+// Unpin removes the entry with the given name from the favourites list. It reports false, if not pinned.
+func (s *State) Unpin(name string) bool {
+	i := s.PinIndex(name)
+	if i < 0 {
+		return false
+	}
+	s.Pinned = append(s.Pinned[:i], s.Pinned[i+1:]...)
+	return true
+}
+
+// Rename moves usage statistics and the favourites list position of the entry to the new name.
+func (s *State) Rename(from string, to string) {
+	if u, ok := s.Usage[from]; ok {
+		delete(s.Usage, from)
+		s.Usage[to] = u
+	}
+	if i := s.PinIndex(from); i >= 0 {
+		s.Pinned[i] = to
+	}
+}
+
+// Allow trusts the file under the given path with the given content hash. When protected is set, the file is trusted
+// to select protected entries as well.
+func (s *State) Allow(path string, hash string, protected bool) {
+	if s.Allowed == nil {
+		s.Allowed = map[string]string{}
+	}
+	s.Allowed[path] = hash
+	s.denyProtected(path)
+	if protected {
+		s.AllowedProtected = append(s.AllowedProtected, path)
+	}
+}
+
+// Deny revokes trust of the file under the given path. It reports false, if the file was not trusted.
+func (s *State) Deny(path string) bool {
+	if _, ok := s.Allowed[path]; !ok {
+		return false
+	}
+	delete(s.Allowed, path)
+	s.denyProtected(path)
+	return true
+}
+
+func (s *State) denyProtected(path string) {
+	for i, p := range s.AllowedProtected {
+		if p == path {
+			s.AllowedProtected = append(s.AllowedProtected[:i], s.AllowedProtected[i+1:]...)
+			return
+		}
+	}
+}
+
+// IsAllowed reports whether the file under the given path is trusted with the given content hash.
+func (s *State) IsAllowed(path string, hash string) bool {
+	h, ok := s.Allowed[path]
+	return ok && h == hash
+}
+
+// IsAllowedProtected reports whether the file under the given path is trusted to select protected entries (see
+// IsAllowed for checking its content).
+func (s *State) IsAllowedProtected(path string) bool {
+	for _, p := range s.AllowedProtected {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}